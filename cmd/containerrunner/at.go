@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	errInvalidAt   = errors.New("invalid time, expected RFC 3339 or local date-time such as 2026-11-01T09:00")
+	errAtAndEvery  = errors.New("at cannot be combined with every or delay")
+	atLocalLayouts = []string{
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+	}
+)
+
+// parseAt parses the run time of a job that runs once at a specific time. It is either
+// in RFC 3339 format, or a date-time without offset in local time.
+func parseAt(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+
+	for _, layout := range atLocalLayouts {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("at '%s': %w", value, errInvalidAt)
+}
+
+// atDone returns whether job runs once at a specific time and has already run successfully.
+func (job *cronJobConfiguration) atDone() bool {
+	if job.At == "" {
+		return false
+	}
+
+	path, err := job.atStampPath()
+	if err != nil {
+		return false
+	}
+
+	_, err = os.Stat(path)
+
+	return err == nil
+}
+
+// markAtDone records that the scheduled run of job, which runs once at a specific time, has succeeded,
+// so that it is not run again after a restart.
+func (job *cronJobConfiguration) markAtDone() error {
+	path, err := job.atStampPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create state directory: %w", err)
+	}
+
+	stamp := fmt.Sprintf("%s %s\n", job.Name, time.Now().Format(time.RFC3339))
+
+	if err := os.WriteFile(path, []byte(stamp), 0o600); err != nil {
+		return fmt.Errorf("write done stamp: %w", err)
+	}
+
+	return nil
+}
+
+// atStampPath returns the path of the file that marks job as done. It depends on the job's
+// name and time, so changing either makes the job run again.
+func (job *cronJobConfiguration) atStampPath() (string, error) {
+	at, err := parseAt(job.At)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := job.jobStateDir()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(job.Name + "\x00" + at.UTC().Format(time.RFC3339)))
+
+	return filepath.Join(dir, "at-done-"+hex.EncodeToString(hash[:8])), nil
+}
+
+// jobStateDir returns the state directory of job, which is the default one if job has not been loaded
+// from a configuration file.
+func (job *cronJobConfiguration) jobStateDir() (string, error) {
+	if job.stateDir != "" {
+		return job.stateDir, nil
+	}
+
+	return stateDir()
+}
+
+// stateDir returns the directory for state that must survive restarts of jobs that have not been loaded
+// from a configuration file.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "containerrunner"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("state directory: %w", err)
+	}
+
+	return filepath.Join(home, ".local", "state", "containerrunner"), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduler_At(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	job := cronJobConfiguration{Name: "rotate", At: start.Add(time.Hour).Format(time.RFC3339)}
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.waitTimers(t, 1)
+	expectNextRun(t, sched, "rotate", start.Add(time.Hour))
+
+	clk.advance(time.Hour)
+	expectRun(t, calls, "rotate", start.Add(time.Hour))
+
+	clk.waitTimers(t, 0)
+	expectNextRun(t, sched, "rotate", time.Time{})
+}
+
+func TestScheduler_AtMissed(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	// runs right away if its time has passed while not running
+	job := cronJobConfiguration{Name: "rotate", At: start.Add(-time.Hour).Format(time.RFC3339)}
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	expectRun(t, calls, "rotate", start)
+}
+
+func TestRunner_RunJobAtDone(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	exe := fakeExecutor{}
+
+	run := newRunner(1024)
+	run.executor = &exe
+
+	job := cronJobConfiguration{Name: "rotate", At: "2026-11-01T09:00", Command: "true"}
+
+	if job.atDone() {
+		t.Fatal("job done before it has run")
+	}
+
+	run.runJobWith(context.Background(), &job, runInfo{trigger: triggerSchedule, scheduledAt: run.clock.Now()})
+
+	if !job.atDone() {
+		t.Fatal("job not done after successful run")
+	}
+
+	// the job is not scheduled again after a restart
+	sched, _ := newTestScheduler(t, newFakeClock())
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNextRun(t, sched, "rotate", time.Time{})
+}
+
+func TestRunner_RunJobAtDoneSuccessExitCode(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	run := newRunner(1024)
+	run.executor = &fakeExecutor{code: 3, err: errors.New("run: exit status 3")}
+
+	job := cronJobConfiguration{Name: "rotate", At: "2026-11-01T09:00", Command: "true", SuccessExitCodes: []int{3}}
+
+	run.runJobWith(context.Background(), &job, runInfo{trigger: triggerSchedule, scheduledAt: run.clock.Now()})
+
+	if !job.atDone() {
+		t.Fatal("job not done after run with success exit code")
+	}
+}
+
+func TestRunner_RunJobAtManual(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	clk := newFakeClock()
+
+	run := newRunner(1024)
+	run.clock = clk
+	run.executor = &fakeExecutor{}
+
+	at := clk.Now().Add(time.Hour)
+	job := cronJobConfiguration{Name: "rotate", At: at.Format(time.RFC3339), Command: "true"}
+
+	// triggering the job, for example to test it, does not complete it
+	for _, trigger := range []string{triggerManual, triggerWatch} {
+		run.runJobWith(context.Background(), &job, runInfo{trigger: trigger, scheduledAt: clk.Now()})
+	}
+
+	if job.atDone() {
+		t.Fatal("job done after manual run")
+	}
+
+	sched, _ := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNextRun(t, sched, "rotate", at)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// logsCmd implements the "logs" subcommand, which prints the recent output of a job
+// from the running instance.
+func logsCmd(args []string) int {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+
+	var (
+		runID  string
+		follow bool
+	)
+
+	controlPath := defaultControlSocket()
+
+	flags.StringVar(&controlPath, "control", controlPath, "path to control API socket of the running instance")
+	flags.StringVar(&runID, "run", runID, "print output of the run with this ID instead of the job's recent output")
+	flags.BoolVar(&follow, "f", follow, "stream the output of the current run until it finishes")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s logs [-control <path>] [-run <id>] [-f] <job>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, errMissingJobName.Error())
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	query := url.Values{}
+
+	if runID != "" {
+		query.Set("run", runID)
+	}
+
+	if follow {
+		query.Set("follow", "1")
+	}
+
+	res, err := controlGet(ctx, controlPath, "/jobs/"+url.PathEscape(flags.Arg(0))+"/logs?"+query.Encode())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("get logs: %w", err).Error())
+		return exitRuntime
+	}
+	defer res.Body.Close()
+
+	if _, err := io.Copy(os.Stdout, res.Body); err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("read logs: %w", err).Error())
+		return exitRuntime
+	}
+
+	return 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var errInvalidCount = errors.New("number of run times must be at least 1")
+
+// nextCmd implements the "next" subcommand, which prints the upcoming run times of jobs
+// as if the runner was started now, without running anything.
+func nextCmd(args []string) int {
+	flags := flag.NewFlagSet("next", flag.ExitOnError)
+
+	var (
+		configPath   string
+		configFormat string
+		jobName      string
+	)
+
+	count := 5
+
+	flags.StringVar(&configPath, "config", configPath, "path to config file")
+	flags.StringVar(&configFormat, "config-format", configFormat, "config file format (yaml, json or toml), by file extension if empty")
+	flags.IntVar(&count, "n", count, "number of run times to print per job")
+	flags.StringVar(&jobName, "job", jobName, "only print run times of this job")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s next -config <path> [-n <count>] [-job <job>]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, errMissingConfigPath.Error())
+		return exitUsage
+	}
+
+	if count < 1 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("-n %d: %w", count, errInvalidCount).Error())
+		return exitUsage
+	}
+
+	config, err := loadConfig(configPath, configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("load configuration: %w", err).Error())
+		return exitConfig
+	}
+
+	jobs := []*cronJobConfiguration{}
+
+	if jobName != "" {
+		job, ok := config.job(jobName)
+		if !ok {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("%s: %w", jobName, errUnknownJob).Error())
+			return exitUsage
+		}
+
+		jobs = append(jobs, job)
+	} else if config.Cron != nil {
+		jobs = config.Cron.Jobs
+	}
+
+	now := time.Now()
+	code := 0
+
+	for _, job := range jobs {
+		fmt.Printf("%s (%s):\n", job.Name, job.describeSchedule())
+
+		runs, err := nextAllowedRuns(job, config.Cron.Blackout, now, count)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': %w", job.Name, err).Error())
+
+			code = exitConfig
+
+			continue
+		}
+
+		if len(runs) == 0 {
+			fmt.Println("  no scheduled runs")
+		}
+
+		for _, run := range runs {
+			fmt.Printf("  %s (in %s)", run.at.Format(time.DateTime), run.at.Sub(now).Round(time.Second))
+
+			if run.reason != "" {
+				fmt.Printf(" %s", run.reason)
+			}
+
+			fmt.Println()
+		}
+	}
+
+	return code
+}
+
+// nextAllowedRuns returns up to count runs of job as if the scheduler was started at now.
+func nextAllowedRuns(job *cronJobConfiguration, blackouts []*blackoutConfiguration, now time.Time, count int,
+) ([]runWindow, error) {
+	runs := make([]runWindow, 0, count)
+
+	for after := now; len(runs) < count; {
+		run, ok, err := nextAllowedRun(job, blackouts, now, after)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			break
+		}
+
+		runs = append(runs, run)
+		after = run.at.Add(time.Nanosecond)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	errMissingJobName = errors.New("missing job name")
+	errUnknownJob     = errors.New("unknown job")
+)
+
+// runCmd implements the "run" subcommand, which runs a single job in the foreground
+// and exits with the job's exit code.
+func runCmd(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+
+	var (
+		configPath   string
+		configFormat string
+		dryRun       bool
+	)
+
+	flags.StringVar(&configPath, "config", configPath, "path to config file")
+	flags.StringVar(&configFormat, "config-format", configFormat, "config file format (yaml, json or toml), by file extension if empty")
+	flags.BoolVar(&dryRun, "dry-run", dryRun, "print the resolved command without running it")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s run -config <path> [-dry-run] <job>\n", os.Args[0])
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output())
+		fmt.Fprintf(flags.Output(), "Exits with the job's exit code, or %d on usage and %d on configuration errors.\n", exitUsage, exitConfig)
+	}
+
+	_ = flags.Parse(args)
+
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, errMissingConfigPath.Error())
+		return exitUsage
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, errMissingJobName.Error())
+		return exitUsage
+	}
+
+	config, err := loadConfig(configPath, configFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("load configuration: %w", err).Error())
+		return exitConfig
+	}
+
+	name := flags.Arg(0)
+
+	job, ok := config.job(name)
+	if !ok {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("%s: %w", name, errUnknownJob).Error())
+		return exitUsage
+	}
+
+	if dryRun {
+		if err := printJob(job); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return exitConfig
+		}
+
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	info := runInfo{
+		id:          newRunID(),
+		trigger:     triggerManual,
+		scheduledAt: time.Now(),
+	}
+
+	result, err := runCommand(ctx, newJobExecutor(), job, info, os.Stdout)
+
+	code := result.ExitCode
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("run job '%s': %w", job.Name, err).Error())
+
+		if code <= 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func printJob(job *cronJobConfiguration) error {
+	if _, err := job.timeout(); err != nil {
+		return err
+	}
+
+	limitArgs, err := job.limitArgs()
+	if err != nil {
+		return fmt.Errorf("limits: %w", err)
+	}
+
+	fmt.Printf("job: %s\n", job.Name)
+
+	if job.Container != "" {
+		fmt.Printf("container: %s\n", job.Container)
+	}
+
+	if job.Dir != "" {
+		fmt.Printf("dir: %s\n", job.Dir)
+	}
+
+	for _, env := range jobEnv(job) {
+		fmt.Printf("env: %s\n", env)
+	}
+
+	if job.Timeout != "" {
+		fmt.Printf("timeout: %s\n", job.Timeout)
+	}
+
+	if len(job.Locks) > 0 {
+		fmt.Printf("locks: %s\n", strings.Join(job.Locks, ", "))
+	}
+
+	if len(limitArgs) > 0 {
+		fmt.Printf("limits: %s\n", strings.Join(limitArgs, " "))
+	}
+
+	if job.Backup == nil {
+		fmt.Printf("command: %s\n", formatCommand(job.Command, job.Args))
+		return nil
+	}
+
+	if err := job.Backup.validate(job); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	fmt.Printf("command: %s\n", formatCommand(job.resticCommand(), job.Backup.backupArgs()))
+
+	if forgetArgs := job.Backup.forgetArgs(); forgetArgs != nil {
+		fmt.Printf("command: %s\n", formatCommand(job.resticCommand(), forgetArgs))
+	}
+
+	if job.Backup.CheckEvery != "" {
+		fmt.Printf("command (every %s): %s\n", job.Backup.CheckEvery, formatCommand(job.resticCommand(), job.Backup.resticArgs("check")))
+	}
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	keyUp = iota + 256
+	keyDown
+)
+
+// topView is the state of the "top" subcommand's screen.
+type topView struct {
+	controlPath string
+
+	jobs     []jobInfo
+	selected int
+	message  string
+
+	// name of the job whose output is shown, or empty to show the job table
+	outputJob string
+}
+
+// topCmd implements the "top" subcommand, which shows an auto-refreshing overview of all jobs
+// of the running instance.
+func topCmd(args []string) int {
+	flags := flag.NewFlagSet("top", flag.ExitOnError)
+
+	controlPath := defaultControlSocket()
+	interval := time.Second
+
+	flags.StringVar(&controlPath, "control", controlPath, "path to control API socket of the running instance")
+	flags.DurationVar(&interval, "interval", interval, "refresh interval")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s top [-control <path>] [-interval <duration>]\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "keys: up/down or k/j select, t trigger, p pause, r resume, l or enter show output, q quit")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if interval <= 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("-interval %s: %w", interval, errInvalidInterval).Error())
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitRuntime
+	}
+	defer restore()
+
+	// hide cursor and restore it on exit
+	fmt.Print("\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[H\x1b[2J")
+
+	keys := make(chan int)
+
+	go readKeys(os.Stdin, keys)
+
+	view := topView{
+		controlPath: controlPath,
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		view.refresh(ctx)
+		view.render()
+
+		select {
+		case <-ctx.Done():
+			return 0
+
+		case key, ok := <-keys:
+			if !ok || !view.handleKey(ctx, key) {
+				return 0
+			}
+
+		case <-ticker.C:
+		}
+	}
+}
+
+func (v *topView) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	jobs := []jobInfo{}
+
+	if err := controlGetJSON(ctx, v.controlPath, "/jobs", &jobs); err != nil {
+		v.message = err.Error()
+		return
+	}
+
+	v.jobs = jobs
+
+	if v.selected >= len(v.jobs) {
+		v.selected = max(len(v.jobs)-1, 0)
+	}
+}
+
+// handleKey handles a key press and returns false if the program should quit.
+func (v *topView) handleKey(ctx context.Context, key int) bool {
+	if v.outputJob != "" {
+		v.outputJob = ""
+		return key != 'q'
+	}
+
+	switch key {
+	case 'q':
+		return false
+
+	case keyUp, 'k':
+		v.selected = max(v.selected-1, 0)
+
+	case keyDown, 'j':
+		v.selected = min(v.selected+1, max(len(v.jobs)-1, 0))
+
+	case 't':
+		v.action(ctx, "trigger", "triggered")
+
+	case 'p':
+		v.action(ctx, "pause", "paused")
+
+	case 'r':
+		v.action(ctx, "resume", "resumed")
+
+	case 'l', '\r', '\n':
+		if job, ok := v.selectedJob(); ok {
+			v.outputJob = job.Name
+		}
+	}
+
+	return true
+}
+
+func (v *topView) action(ctx context.Context, action string, done string) {
+	job, ok := v.selectedJob()
+	if !ok {
+		return
+	}
+
+	if err := controlPost(ctx, v.controlPath, "/jobs/"+url.PathEscape(job.Name)+"/"+action); err != nil {
+		v.message = fmt.Sprintf("%s %s: %s", action, job.Name, err.Error())
+		return
+	}
+
+	v.message = fmt.Sprintf("%s %s", done, job.Name)
+}
+
+func (v *topView) selectedJob() (jobInfo, bool) {
+	if v.selected < 0 || v.selected >= len(v.jobs) {
+		return jobInfo{}, false
+	}
+
+	return v.jobs[v.selected], true
+}
+
+func (v *topView) render() {
+	rows, cols := terminalSize(int(os.Stdout.Fd()))
+
+	var screen bytes.Buffer
+
+	screen.WriteString("\x1b[H\x1b[2J")
+
+	if v.outputJob != "" {
+		v.renderOutput(&screen, rows, cols)
+	} else {
+		v.renderJobs(&screen, cols)
+	}
+
+	_, _ = os.Stdout.Write(screen.Bytes())
+}
+
+func (v *topView) renderJobs(screen *bytes.Buffer, cols int) {
+	now := time.Now()
+
+	fmt.Fprintf(screen, "containerrunner - %s - %d jobs\n\n", now.Format(time.DateTime), len(v.jobs))
+
+	var table bytes.Buffer
+
+	tab := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tab, "JOB\tSCHEDULE\tSTATE\tLAST RESULT\tNEXT RUN")
+
+	for _, job := range v.jobs {
+		fmt.Fprintf(tab, "%s\t%s\t%s\t%s\t%s\n", job.Name, job.Schedule, jobState(job), lastResult(job, now), nextRun(job, now))
+	}
+
+	_ = tab.Flush()
+
+	for idx, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+		line = truncate(line, cols)
+
+		if idx-1 == v.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+
+		screen.WriteString(line + "\n")
+	}
+
+	fmt.Fprintf(screen, "\n%s\n\n", v.message)
+	screen.WriteString("up/down select  t trigger  p pause  r resume  l output  q quit\n")
+}
+
+func (v *topView) renderOutput(screen *bytes.Buffer, rows int, cols int) {
+	fmt.Fprintf(screen, "output of %s (press any key to return)\n\n", v.outputJob)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := controlGet(ctx, v.controlPath, "/jobs/"+url.PathEscape(v.outputJob)+"/logs")
+	if err != nil {
+		screen.WriteString(err.Error() + "\n")
+		return
+	}
+	defer res.Body.Close()
+
+	output, err := io.ReadAll(res.Body)
+	if err != nil {
+		screen.WriteString(err.Error() + "\n")
+		return
+	}
+
+	// the header takes up two rows, and the cursor is left on the last one
+	for _, line := range lastLines(string(output), rows-3) {
+		screen.WriteString(truncate(line, cols) + "\n")
+	}
+}
+
+func jobState(job jobInfo) string {
+	switch {
+	case job.Running > 0:
+		return "running"
+	case job.Paused:
+		return "paused"
+	default:
+		return "idle"
+	}
+}
+
+func lastResult(job jobInfo, now time.Time) string {
+	run := job.LastRun
+
+	switch {
+	case run == nil:
+		return "-"
+	case run.End.IsZero():
+		return "started " + formatAge(now.Sub(run.Start)) + " ago"
+	case run.Skipped != "":
+		return "skipped, " + formatAge(now.Sub(run.End)) + " ago"
+	case run.Error == "":
+		return "ok, " + formatAge(now.Sub(run.End)) + " ago"
+	case run.ExitCode > 0:
+		return fmt.Sprintf("exit %d, %s ago", run.ExitCode, formatAge(now.Sub(run.End)))
+	default:
+		return "failed, " + formatAge(now.Sub(run.End)) + " ago"
+	}
+}
+
+func nextRun(job jobInfo, now time.Time) string {
+	if job.NextRun == nil {
+		return "-"
+	}
+
+	return "in " + formatAge(job.NextRun.Sub(now))
+}
+
+func formatAge(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+
+	return d.Round(time.Minute).String()
+}
+
+// lastLines returns at most the last count lines of text.
+func lastLines(text string, count int) []string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	count = max(count, 0)
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+
+	return lines
+}
+
+func truncate(line string, cols int) string {
+	runes := []rune(line)
+	if len(runes) <= cols {
+		return line
+	}
+
+	return string(runes[:cols])
+}
+
+// readKeys reads key presses from input and sends them to keys, translating arrow key escape sequences.
+// keys is closed when input is closed.
+func readKeys(input io.Reader, keys chan<- int) {
+	defer close(keys)
+
+	buf := make([]byte, 16)
+
+	for {
+		count, err := input.Read(buf)
+		if err != nil {
+			return
+		}
+
+		data := buf[:count]
+
+		switch {
+		case bytes.Equal(data, []byte("\x1b[A")):
+			keys <- keyUp
+		case bytes.Equal(data, []byte("\x1b[B")):
+			keys <- keyDown
+		default:
+			for _, key := range data {
+				keys <- int(key)
+			}
+		}
+	}
+}
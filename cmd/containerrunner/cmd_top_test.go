@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLastLines(t *testing.T) {
+	for _, test := range []struct {
+		text  string
+		count int
+		want  []string
+	}{
+		{"a\nb\nc\n", 2, []string{"b", "c"}},
+		{"a\nb\nc", 5, []string{"a", "b", "c"}},
+		{"a\nb\n", 0, []string{}},
+		{"a\nb\n", -2, []string{}},
+	} {
+		if got := lastLines(test.text, test.count); !slices.Equal(got, test.want) {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", test.text, test.count, got, test.want)
+		}
+	}
+}
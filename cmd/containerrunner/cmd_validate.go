@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// validateCmd implements the "validate" subcommand, which loads the configuration, including its init tasks,
+// checks all jobs, and optionally prints the configuration with defaults and templates applied to the jobs.
+func validateCmd(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+
+	var (
+		configPath  string
+		format      string
+		printConfig bool
+	)
+
+	flags.StringVar(&configPath, "config", configPath, "path to config file")
+	flags.StringVar(&format, "config-format", format, "config file format (yaml, json or toml), by file extension if empty")
+	flags.BoolVar(&printConfig, "print", printConfig, "print the resolved configuration, with defaults and templates applied to jobs")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s validate -config <path> [-print]\n", os.Args[0])
+		flags.PrintDefaults()
+		fmt.Fprintln(flags.Output())
+		fmt.Fprintln(flags.Output(), "Jobs are based on the defaults, then on the templates they extend, then on their own settings.")
+		fmt.Fprintln(flags.Output(), "Maps are merged, lists replaced, nested settings merged, and other settings replaced if not empty.")
+	}
+
+	_ = flags.Parse(args)
+
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, errMissingConfigPath.Error())
+		return exitUsage
+	}
+
+	config, err := loadConfig(configPath, format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("load configuration: %w", err).Error())
+		return exitConfig
+	}
+
+	code := 0
+
+	for _, job := range config.cron().Jobs {
+		if err := job.validate(config.cron().Blackout); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': %w", job.Name, err).Error())
+
+			code = exitConfig
+		}
+	}
+
+	if !printConfig {
+		return code
+	}
+
+	format, _ = configFormat(configPath, format)
+
+	resolved := configuration{
+		Init:     config.Init,
+		Cron:     config.Cron,
+		StateDir: config.StateDir,
+	}
+
+	if err := encodeConfig(os.Stdout, format, &resolved); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("print configuration: %w", err).Error())
+		return exitRuntime
+	}
+
+	return code
+}
+
+// validate checks all settings of job that are otherwise only checked when it is scheduled or run.
+func (job *cronJobConfiguration) validate(blackouts []*blackoutConfiguration) error {
+	var errs []error
+
+	now := time.Now()
+
+	if _, _, err := nextAllowedRun(job, blackouts, now, now); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := job.timeout(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := job.limitArgs(); err != nil {
+		errs = append(errs, fmt.Errorf("limits: %w", err))
+	}
+
+	if job.Container != "" && job.hasLimits() {
+		errs = append(errs, errContainerLimits)
+	}
+
+	if _, _, err := job.loadDeferral(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := newOutcomeRules(job); err != nil {
+		errs = append(errs, err)
+	}
+
+	if job.Watch != nil {
+		if err := job.Watch.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("watch: %w", err))
+		}
+	}
+
+	if job.Heartbeat != nil && job.Heartbeat.Timeout != "" {
+		if _, err := time.ParseDuration(job.Heartbeat.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("heartbeat timeout '%s': %w", job.Heartbeat.Timeout, err))
+		}
+	}
+
+	if job.Backup != nil {
+		if err := job.Backup.validate(job); err != nil {
+			errs = append(errs, fmt.Errorf("backup: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// encodeConfig writes config to out in the given format.
+func encodeConfig(out io.Writer, format string, config *configuration) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(config); err != nil {
+			return fmt.Errorf("encode json: %w", err)
+		}
+
+	case formatTOML:
+		if err := toml.NewEncoder(out).Encode(config); err != nil {
+			return fmt.Errorf("encode toml: %w", err)
+		}
+
+	default:
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("encode yaml: %w", err)
+		}
+
+		if _, err := out.Write(data); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+
+	return nil
+}
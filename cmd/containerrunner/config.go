@@ -4,27 +4,139 @@ import (
 	"errors"
 	"fmt"
 	"os"
-
-	"gopkg.in/yaml.v2"
+	"path/filepath"
 )
 
 type configuration struct {
-	Cron *cronConfiguration `yaml:"cron,omitempty"`
+	Init []*initTaskConfiguration `yaml:"init,omitempty" json:"init,omitempty" toml:"init,omitempty"`
+	Cron *cronConfiguration       `yaml:"cron,omitempty" json:"cron,omitempty" toml:"cron,omitempty"`
+
+	// settings of all jobs, and named sets of settings that jobs can extend; see resolveJobs for how they are merged
+	Defaults  *cronJobConfiguration            `yaml:"defaults,omitempty" json:"defaults,omitempty" toml:"defaults,omitempty"`
+	Templates map[string]*cronJobConfiguration `yaml:"templates,omitempty" json:"templates,omitempty" toml:"templates,omitempty"`
+
+	// directory for state that must survive restarts, such as done stamps of at: jobs; relative paths
+	// are relative to the configuration file, and the default is "state" next to it
+	StateDir string `yaml:"state_dir,omitempty" json:"state_dir,omitempty" toml:"state_dir,omitempty"`
+}
+
+// initTaskConfiguration is a task that runs once, in order, before jobs are scheduled.
+// A task either waits for a condition or runs a command.
+type initTaskConfiguration struct {
+	Name      string             `yaml:"name,omitempty" json:"name,omitempty" toml:"name,omitempty"`
+	Wait      *waitConfiguration `yaml:"wait,omitempty" json:"wait,omitempty" toml:"wait,omitempty"`
+	Command   string             `yaml:"command,omitempty" json:"command,omitempty" toml:"command,omitempty"`
+	Args      []string           `yaml:"args,omitempty" json:"args,omitempty" toml:"args,omitempty"`
+	Env       map[string]string  `yaml:"env,omitempty" json:"env,omitempty" toml:"env,omitempty"`
+	Dir       string             `yaml:"dir,omitempty" json:"dir,omitempty" toml:"dir,omitempty"`
+	Timeout   string             `yaml:"timeout,omitempty" json:"timeout,omitempty" toml:"timeout,omitempty"`
+	OnFailure string             `yaml:"on_failure,omitempty" json:"on_failure,omitempty" toml:"on_failure,omitempty"`
+}
+
+type waitConfiguration struct {
+	TCP  string `yaml:"tcp,omitempty" json:"tcp,omitempty" toml:"tcp,omitempty"`
+	HTTP string `yaml:"http,omitempty" json:"http,omitempty" toml:"http,omitempty"`
+	File string `yaml:"file,omitempty" json:"file,omitempty" toml:"file,omitempty"`
 }
 
 type cronConfiguration struct {
-	Jobs []*cronJobConfiguration `yaml:"jobs,omitempty"`
+	Jobs     []*cronJobConfiguration  `yaml:"jobs,omitempty" json:"jobs,omitempty" toml:"jobs,omitempty"`
+	Blackout []*blackoutConfiguration `yaml:"blackout,omitempty" json:"blackout,omitempty" toml:"blackout,omitempty"`
+}
+
+// blackoutConfiguration is a period of time in which no jobs run. Runs that fall into it are
+// either skipped or deferred to its end.
+type blackoutConfiguration struct {
+	Between  string   `yaml:"between,omitempty" json:"between,omitempty" toml:"between,omitempty"`
+	Weekdays []string `yaml:"weekdays,omitempty" json:"weekdays,omitempty" toml:"weekdays,omitempty"`
+	Action   string   `yaml:"action,omitempty" json:"action,omitempty" toml:"action,omitempty"`
 }
 
 type cronJobConfiguration struct {
-	Every   string   `yaml:"every"`
-	Delay   string   `yaml:"delay,omitempty"`
-	Command string   `yaml:"command"`
-	Args    []string `yaml:"args,omitempty"`
+	Name    string            `yaml:"name,omitempty" json:"name,omitempty" toml:"name,omitempty"`
+	Extends string            `yaml:"extends,omitempty" json:"extends,omitempty" toml:"extends,omitempty"`
+	Every   string            `yaml:"every" json:"every" toml:"every"`
+	At      string            `yaml:"at,omitempty" json:"at,omitempty" toml:"at,omitempty"`
+	Delay   string            `yaml:"delay,omitempty" json:"delay,omitempty" toml:"delay,omitempty"`
+	Command string            `yaml:"command" json:"command" toml:"command"`
+	Args    []string          `yaml:"args,omitempty" json:"args,omitempty" toml:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty" json:"env,omitempty" toml:"env,omitempty"`
+	Dir     string            `yaml:"dir,omitempty" json:"dir,omitempty" toml:"dir,omitempty"`
+	Timeout string            `yaml:"timeout,omitempty" json:"timeout,omitempty" toml:"timeout,omitempty"`
+
+	// name or ID of a running container to run the command in
+	Container string `yaml:"container,omitempty" json:"container,omitempty" toml:"container,omitempty"`
+
+	SuccessExitCodes       []int  `yaml:"success_exit_codes,omitempty" json:"success_exit_codes,omitempty" toml:"success_exit_codes,omitempty"`
+	FailIfOutputMatches    string `yaml:"fail_if_output_matches,omitempty" json:"fail_if_output_matches,omitempty" toml:"fail_if_output_matches,omitempty"`
+	SucceedIfOutputMatches string `yaml:"succeed_if_output_matches,omitempty" json:"succeed_if_output_matches,omitempty" toml:"succeed_if_output_matches,omitempty"`
+
+	OnlyBetween string   `yaml:"only_between,omitempty" json:"only_between,omitempty" toml:"only_between,omitempty"`
+	Weekdays    []string `yaml:"weekdays,omitempty" json:"weekdays,omitempty" toml:"weekdays,omitempty"`
+
+	Nice   *int                 `yaml:"nice,omitempty" json:"nice,omitempty" toml:"nice,omitempty"`
+	IONice string               `yaml:"ionice,omitempty" json:"ionice,omitempty" toml:"ionice,omitempty"`
+	Limits *limitsConfiguration `yaml:"limits,omitempty" json:"limits,omitempty" toml:"limits,omitempty"`
+
+	MaxLoad           float64 `yaml:"max_load,omitempty" json:"max_load,omitempty" toml:"max_load,omitempty"`
+	MaxMemoryPressure float64 `yaml:"max_memory_pressure,omitempty" json:"max_memory_pressure,omitempty" toml:"max_memory_pressure,omitempty"`
+	LoadRetry         string  `yaml:"load_retry,omitempty" json:"load_retry,omitempty" toml:"load_retry,omitempty"`
+	LoadMaxDelay      string  `yaml:"load_max_delay,omitempty" json:"load_max_delay,omitempty" toml:"load_max_delay,omitempty"`
+
+	// run the job when SIGUSR2 is received
+	OnUSR2 bool `yaml:"on_usr2,omitempty" json:"on_usr2,omitempty" toml:"on_usr2,omitempty"`
+
+	Locks    []string `yaml:"locks,omitempty" json:"locks,omitempty" toml:"locks,omitempty"`
+	LockMode string   `yaml:"lock_mode,omitempty" json:"lock_mode,omitempty" toml:"lock_mode,omitempty"`
+
+	Watch *watchConfiguration `yaml:"watch,omitempty" json:"watch,omitempty" toml:"watch,omitempty"`
+
+	Heartbeat *heartbeatConfiguration `yaml:"heartbeat,omitempty" json:"heartbeat,omitempty" toml:"heartbeat,omitempty"`
+
+	Backup *backupConfiguration `yaml:"backup,omitempty" json:"backup,omitempty" toml:"backup,omitempty"`
+
+	// directory for the job's state, which is set from the configuration when it is loaded
+	stateDir string
 }
 
-func loadConfigDefault(path string) (*configuration, bool, error) {
-	config, err := loadConfig(path)
+// backupConfiguration makes a job back up paths into a restic repository.
+type backupConfiguration struct {
+	Repository   string   `yaml:"repository" json:"repository" toml:"repository"`
+	PasswordFile string   `yaml:"password_file,omitempty" json:"password_file,omitempty" toml:"password_file,omitempty"`
+	Paths        []string `yaml:"paths" json:"paths" toml:"paths"`
+	Excludes     []string `yaml:"excludes,omitempty" json:"excludes,omitempty" toml:"excludes,omitempty"`
+	KeepDaily    int      `yaml:"keep_daily,omitempty" json:"keep_daily,omitempty" toml:"keep_daily,omitempty"`
+	KeepWeekly   int      `yaml:"keep_weekly,omitempty" json:"keep_weekly,omitempty" toml:"keep_weekly,omitempty"`
+	KeepMonthly  int      `yaml:"keep_monthly,omitempty" json:"keep_monthly,omitempty" toml:"keep_monthly,omitempty"`
+	CheckEvery   string   `yaml:"check_every,omitempty" json:"check_every,omitempty" toml:"check_every,omitempty"`
+}
+
+// heartbeatConfiguration makes a job ping a monitoring URL when it starts, succeeds or fails.
+type heartbeatConfiguration struct {
+	URL     string `yaml:"url" json:"url" toml:"url"`
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty" toml:"timeout,omitempty"`
+	Retries *int   `yaml:"retries,omitempty" json:"retries,omitempty" toml:"retries,omitempty"`
+}
+
+// limitsConfiguration contains resource limits for a job's process.
+type limitsConfiguration struct {
+	OpenFiles    uint64 `yaml:"open_files,omitempty" json:"open_files,omitempty" toml:"open_files,omitempty"`
+	AddressSpace string `yaml:"address_space,omitempty" json:"address_space,omitempty" toml:"address_space,omitempty"`
+	CPUTime      string `yaml:"cpu_time,omitempty" json:"cpu_time,omitempty" toml:"cpu_time,omitempty"`
+}
+
+type watchConfiguration struct {
+	Paths     []string `yaml:"paths" json:"paths" toml:"paths"`
+	Globs     []string `yaml:"globs,omitempty" json:"globs,omitempty" toml:"globs,omitempty"`
+	Recursive bool     `yaml:"recursive,omitempty" json:"recursive,omitempty" toml:"recursive,omitempty"`
+	Ignore    []string `yaml:"ignore,omitempty" json:"ignore,omitempty" toml:"ignore,omitempty"`
+	Debounce  string   `yaml:"debounce,omitempty" json:"debounce,omitempty" toml:"debounce,omitempty"`
+}
+
+var errDuplicateJobName = errors.New("duplicate job name")
+
+func loadConfigDefault(path string, format string) (*configuration, bool, error) {
+	config, err := loadConfig(path, format)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			return nil, false, fmt.Errorf("load: %w", err)
@@ -36,18 +148,130 @@ func loadConfigDefault(path string) (*configuration, bool, error) {
 	return config, true, nil
 }
 
-func loadConfig(path string) (*configuration, error) {
-	file, err := os.Open(path)
+// loadConfig loads the configuration file at path in the given format, which is one of "yaml", "json" or "toml".
+// If format is empty, it is chosen by the file's extension.
+func loadConfig(path string, format string) (*configuration, error) {
+	format, err := configFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("open: %w", err)
+		return nil, fmt.Errorf("read: %w", err)
 	}
-	defer file.Close()
 
 	var config configuration
 
-	if err := yaml.NewDecoder(file).Decode(&config); err != nil {
+	if err := decodeConfig(path, format, data, &config); err != nil {
 		return nil, fmt.Errorf("decode: %w", err)
 	}
 
+	if err := config.resolveJobs(); err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+
+	if err := config.assignJobNames(); err != nil {
+		return nil, fmt.Errorf("job names: %w", err)
+	}
+
+	config.assignStateDir(path)
+
+	if err := config.validateInit(); err != nil {
+		return nil, err
+	}
+
+	if config.Cron != nil {
+		if err := config.Cron.validateBlackouts(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &config, nil
 }
+
+// assignJobNames gives every job without an explicit name the base name of its command,
+// numbering duplicates in order of appearance.
+func (c *configuration) assignJobNames() error {
+	if c.Cron == nil {
+		return nil
+	}
+
+	names := map[string]struct{}{}
+
+	for _, job := range c.Cron.Jobs {
+		if job.Name == "" {
+			continue
+		}
+
+		if _, ok := names[job.Name]; ok {
+			return fmt.Errorf("%s: %w", job.Name, errDuplicateJobName)
+		}
+
+		names[job.Name] = struct{}{}
+	}
+
+	for _, job := range c.Cron.Jobs {
+		if job.Name != "" {
+			continue
+		}
+
+		base := filepath.Base(job.Command)
+		if job.Backup != nil {
+			base = "backup"
+		}
+		name := base
+
+		for idx := 2; ; idx++ {
+			if _, ok := names[name]; !ok {
+				break
+			}
+
+			name = fmt.Sprintf("%s-%d", base, idx)
+		}
+
+		job.Name = name
+		names[name] = struct{}{}
+	}
+
+	return nil
+}
+
+// assignStateDir sets the state directory of all jobs, resolving it relative to the configuration file at path.
+func (c *configuration) assignStateDir(path string) {
+	dir := expandHome(c.StateDir)
+
+	switch {
+	case dir == "":
+		dir = filepath.Join(filepath.Dir(path), "state")
+	case !filepath.IsAbs(dir):
+		dir = filepath.Join(filepath.Dir(path), dir)
+	}
+
+	for _, job := range c.cron().Jobs {
+		job.stateDir = dir
+	}
+}
+
+// cron returns the cron configuration of c, which is empty if c has none.
+func (c *configuration) cron() *cronConfiguration {
+	if c.Cron == nil {
+		return &cronConfiguration{}
+	}
+
+	return c.Cron
+}
+
+func (c *configuration) job(name string) (*cronJobConfiguration, bool) {
+	if c.Cron == nil {
+		return nil, false
+	}
+
+	for _, job := range c.Cron.Jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+
+	return nil, false
+}
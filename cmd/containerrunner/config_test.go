@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	yamlPath := writeConfig(t, "config.yaml", `
+cron:
+  blackout:
+    - between: "08:00-18:00"
+      action: defer
+  jobs:
+    - name: prune
+      every: 24h
+      command: docker
+      args: [system, prune, -f]
+      env:
+        DOCKER_HOST: unix:///var/run/docker.sock
+      limits:
+        open_files: 1024
+`)
+
+	jsonPath := writeConfig(t, "config.json", `{
+  "cron": {
+    "blackout": [{"between": "08:00-18:00", "action": "defer"}],
+    "jobs": [
+      {
+        "name": "prune",
+        "every": "24h",
+        "command": "docker",
+        "args": ["system", "prune", "-f"],
+        "env": {"DOCKER_HOST": "unix:///var/run/docker.sock"},
+        "limits": {"open_files": 1024}
+      }
+    ]
+  }
+}`)
+
+	tomlPath := writeConfig(t, "config.toml", `
+[[cron.blackout]]
+between = "08:00-18:00"
+action = "defer"
+
+[[cron.jobs]]
+name = "prune"
+every = "24h"
+command = "docker"
+args = ["system", "prune", "-f"]
+env = { DOCKER_HOST = "unix:///var/run/docker.sock" }
+limits = { open_files = 1024 }
+`)
+
+	want, err := loadConfig(yamlPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(want.Cron.Jobs) != 1 || want.Cron.Jobs[0].Limits.OpenFiles != 1024 {
+		t.Fatalf("unexpected YAML configuration: %+v", want.Cron)
+	}
+
+	for _, path := range []string{jsonPath, tomlPath} {
+		config, err := loadConfig(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the state directory is next to each file
+		config.Cron.Jobs[0].stateDir = want.Cron.Jobs[0].stateDir
+
+		if !reflect.DeepEqual(config, want) {
+			t.Errorf("%s: configuration differs from YAML", filepath.Base(path))
+		}
+	}
+}
+
+func TestLoadConfig_FormatOverride(t *testing.T) {
+	path := writeConfig(t, "jobs.conf", `{"cron": {"jobs": [{"command": "true"}]}}`)
+
+	if _, err := loadConfig(path, "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadConfig(path, "ini"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestLoadConfig_UnknownField(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"config.yaml", "cron:\n  jobs:\n    - command: \"true\"\n      evry: 1h\n", "config.yaml:4: field evry not found"},
+		{"config.json", "{\"cron\": {\"jobs\": [\n  {\"command\": \"true\", \"evry\": \"1h\"}\n]}}", "config.json:2:"},
+		{"config.toml", "[[cron.jobs]]\ncommand = \"true\"\nevry = \"1h\"\n", "config.toml:3: unknown field cron.jobs.evry"},
+	} {
+		_, err := loadConfig(writeConfig(t, test.name, test.content), "")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestLoadConfig_Templates(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+defaults:
+  timeout: 1h
+  env:
+    TZ: UTC
+templates:
+  base:
+    env:
+      LEVEL: info
+    locks: [db]
+  web:
+    extends: base
+    dir: /srv
+    limits:
+      open_files: 100
+cron:
+  jobs:
+    - name: web
+      every: 1h
+      command: serve
+      extends: web
+      env:
+        LEVEL: debug
+      locks: []
+      limits:
+        cpu_time: 1m
+    - name: plain
+      every: 5m
+      command: "true"
+`)
+
+	config, err := loadConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*cronJobConfiguration{
+		{
+			Name:    "web",
+			Every:   "1h",
+			Command: "serve",
+			Env:     map[string]string{"TZ": "UTC", "LEVEL": "debug"},
+			Dir:     "/srv",
+			Timeout: "1h",
+			Locks:   []string{},
+			Limits:  &limitsConfiguration{OpenFiles: 100, CPUTime: "1m"},
+
+			stateDir: filepath.Join(filepath.Dir(path), "state"),
+		},
+		{
+			Name:    "plain",
+			Every:   "5m",
+			Command: "true",
+			Env:     map[string]string{"TZ": "UTC"},
+			Timeout: "1h",
+
+			stateDir: filepath.Join(filepath.Dir(path), "state"),
+		},
+	}
+
+	if !reflect.DeepEqual(config.Cron.Jobs, want) {
+		t.Errorf("jobs = %+v, want %+v", config.Cron.Jobs, want)
+	}
+}
+
+func TestLoadConfig_TemplateErrors(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    error
+	}{
+		{"cron:\n  jobs:\n    - command: \"true\"\n      extends: missing\n", errUnknownTemplate},
+		{"templates:\n  a:\n    extends: b\n  b:\n    extends: a\n", errTemplateCycle},
+		{"templates:\n  a:\n    name: job\n", errTemplateName},
+		{"defaults:\n  extends: a\ntemplates:\n  a:\n    dir: /\n", errDefaultsExtends},
+	} {
+		_, err := loadConfig(writeConfig(t, "config.yaml", test.content), "")
+		if !errors.Is(err, test.want) {
+			t.Errorf("%q: err = %v, want %v", test.content, err, test.want)
+		}
+	}
+}
+
+func TestLoadConfig_StateDir(t *testing.T) {
+	for _, test := range []struct {
+		stateDir string
+		want     func(dir string) string
+	}{
+		{"", func(dir string) string { return filepath.Join(dir, "state") }},
+		{"data/state", func(dir string) string { return filepath.Join(dir, "data", "state") }},
+		{"/var/lib/containerrunner", func(string) string { return "/var/lib/containerrunner" }},
+	} {
+		content := "cron:\n  jobs:\n    - command: \"true\"\n"
+		if test.stateDir != "" {
+			content = "state_dir: " + test.stateDir + "\n" + content
+		}
+
+		path := writeConfig(t, "config.yaml", content)
+
+		config, err := loadConfig(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := config.Cron.Jobs[0].stateDir, test.want(filepath.Dir(path)); got != want {
+			t.Errorf("state_dir %q: state directory = %s, want %s", test.stateDir, got, want)
+		}
+	}
+}
+
+func TestLoadConfig_InitTasks(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    error
+	}{
+		{"init:\n  - name: both\n    command: \"true\"\n    wait:\n      file: /tmp/ready\n", errInitTaskKind},
+		{"init:\n  - wait: {}\n", errInitWaitEmpty},
+		{"init:\n  - command: \"true\"\n    on_failure: retry\n", errInitTaskOnFailure},
+	} {
+		_, err := loadConfig(writeConfig(t, "config.yaml", test.content), "")
+		if !errors.Is(err, test.want) {
+			t.Errorf("%q: err = %v, want %v", test.content, err, test.want)
+		}
+	}
+
+	if _, err := loadConfig(writeConfig(t, "config.yaml", "init:\n  - command: \"true\"\n    timeout: soon\n"), ""); err == nil {
+		t.Error("expected error for invalid timeout")
+	}
+}
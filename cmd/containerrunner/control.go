@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// controlBaseURL is the base URL of control API requests. The host is ignored.
+const controlBaseURL = "http://containerrunner"
+
+var (
+	errControlSocketInUse = errors.New("control socket is in use by another instance")
+	errControlRequest     = errors.New("control API request failed")
+	errUnknownRun         = errors.New("unknown run")
+)
+
+func defaultControlSocket() string {
+	return filepath.Join(os.TempDir(), "containerrunner.sock")
+}
+
+// startControl serves the control API for r on a Unix socket at path.
+// The returned function shuts the server down.
+func startControl(path string, r *runner) (func(), error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s: %w", path, errControlSocketInUse)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, r.jobInfos())
+	})
+
+	mux.HandleFunc("GET /jobs/{name}/history", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, r.jobHistory(req.PathValue("name")))
+	})
+
+	mux.HandleFunc("POST /jobs/{name}/trigger", func(w http.ResponseWriter, req *http.Request) {
+		writeResult(w, r.trigger(req.PathValue("name")))
+	})
+
+	mux.HandleFunc("POST /jobs/{name}/pause", func(w http.ResponseWriter, req *http.Request) {
+		writeResult(w, r.setPaused(req.PathValue("name"), true))
+	})
+
+	mux.HandleFunc("POST /jobs/{name}/resume", func(w http.ResponseWriter, req *http.Request) {
+		writeResult(w, r.setPaused(req.PathValue("name"), false))
+	})
+
+	shutdown := make(chan struct{})
+
+	mux.HandleFunc("GET /jobs/{name}/logs", func(w http.ResponseWriter, req *http.Request) {
+		serveLogs(w, req, r, shutdown)
+	})
+
+	server := http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	server.RegisterOnShutdown(func() {
+		close(shutdown)
+	})
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("serve control API: %w", err).Error())
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(ctx)
+	}, nil
+}
+
+// serveLogs writes the buffered output of a job, or of a single run if the "run" query parameter
+// is set. If the "follow" query parameter is set, it keeps streaming the job's output until the
+// client disconnects or the server shuts down.
+func serveLogs(w http.ResponseWriter, req *http.Request, r *runner, shutdown <-chan struct{}) {
+	name := req.PathValue("name")
+
+	if !r.hasJob(name) {
+		http.Error(w, fmt.Sprintf("%s: %s", name, errUnknownJob.Error()), http.StatusNotFound)
+		return
+	}
+
+	output := r.jobOutput(name)
+	follow := req.URL.Query().Get("follow") != ""
+
+	if id := req.URL.Query().Get("run"); id != "" {
+		var ok bool
+		if output, ok = r.runOutput(name, id); !ok {
+			http.Error(w, fmt.Sprintf("%s: %s", id, errUnknownRun.Error()), http.StatusNotFound)
+			return
+		}
+	} else if follow {
+		// following a job follows its current run, if there is one
+		var ok bool
+		if output, ok = r.currentRunOutput(name); !ok {
+			output = r.jobOutput(name)
+			follow = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if !follow {
+		_, _ = w.Write(output.Bytes())
+		return
+	}
+
+	data, chunks, unfollow := output.follow()
+	defer unfollow()
+
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return
+
+		case <-shutdown:
+			return
+
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+
+			data = chunk
+		}
+	}
+}
+
+// unixSocketClient returns an HTTP client that connects to the Unix socket at path.
+func unixSocketClient(path string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+// controlGet sends a GET request for path to the control API at socketPath.
+// Responses with a status other than 200 are returned as errors.
+func controlGet(ctx context.Context, socketPath string, path string) (*http.Response, error) {
+	return controlRequest(ctx, http.MethodGet, socketPath, path)
+}
+
+// controlPost sends a POST request for path to the control API at socketPath.
+func controlPost(ctx context.Context, socketPath string, path string) error {
+	res, err := controlRequest(ctx, http.MethodPost, socketPath, path)
+	if err != nil {
+		return err
+	}
+
+	_ = res.Body.Close()
+
+	return nil
+}
+
+// controlGetJSON sends a GET request for path to the control API at socketPath and decodes the response into value.
+func controlGetJSON(ctx context.Context, socketPath string, path string, value any) error {
+	res, err := controlGet(ctx, socketPath, path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(value); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+func controlRequest(ctx context.Context, method string, socketPath string, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, controlBaseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	res, err := unixSocketClient(socketPath).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connect to running instance: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+
+		msg, _ := io.ReadAll(res.Body)
+
+		return nil, fmt.Errorf("%w: %s", errControlRequest, strings.TrimSpace(string(msg)))
+	}
+
+	return res, nil
+}
+
+// writeResult writes an empty response, or err as a plain text error.
+func writeResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, errUnknownJob):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("write response: %w", err).Error())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestControl_Logs(t *testing.T) {
+	run := newRunner(1024)
+
+	job := cronJobConfiguration{Name: "job", Command: "true"}
+	run.setJobs(nil, []*cronJobConfiguration{&job})
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+
+	stop, err := startControl(socket, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer stop()
+
+	_, _ = run.jobOutput("job").Write([]byte("old\n"))
+
+	record := run.startRun(&job, runInfo{trigger: triggerManual})
+	_, _ = io.MultiWriter(run.jobOutput("job"), record.output).Write([]byte("first\n"))
+
+	get := func(path string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		res, err := controlGet(ctx, socket, path)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		data, err := io.ReadAll(res.Body)
+
+		return string(data), err
+	}
+
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{"/jobs/job/logs", "old\nfirst\n"},
+		{"/jobs/job/logs?run=" + record.ID, "first\n"},
+	} {
+		if got, err := get(test.path); err != nil || got != test.want {
+			t.Errorf("%s = %q, %v, want %q", test.path, got, err, test.want)
+		}
+	}
+
+	for _, path := range []string{"/jobs/missing/logs", "/jobs/job/logs?run=missing"} {
+		if _, err := get(path); !errors.Is(err, errControlRequest) {
+			t.Errorf("%s: err = %v, want %v", path, err, errControlRequest)
+		}
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+
+	followed := make(chan result, 1)
+
+	go func() {
+		output, err := get("/jobs/job/logs?follow=1")
+		followed <- result{output, err}
+	}()
+
+	for !record.output.hasFollowers() {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, _ = record.output.Write([]byte("second\n"))
+
+	// following ends once the run has finished
+	run.finishRun(record, &runResult{}, nil)
+
+	if got := <-followed; got.err != nil || got.output != "first\nsecond\n" {
+		t.Errorf("followed = %q, %v, want the output of the current run", got.output, got.err)
+	}
+
+	if got, err := get("/jobs/job/logs?follow=1&run=" + record.ID); err != nil || got != "first\nsecond\n" {
+		t.Errorf("followed finished run = %q, %v, want its output", got, err)
+	}
+
+	// without a current run, there is nothing to follow
+	if got, err := get("/jobs/job/logs?follow=1"); err != nil || got != "old\nfirst\n" {
+		t.Errorf("followed idle job = %q, %v, want the job's output", got, err)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDockerSocket = "/var/run/docker.sock"
+	dockerBaseURL       = "http://docker"
+
+	// stream types of the Docker multiplexed stream format
+	dockerStdout = 1
+	dockerStderr = 2
+
+	// the exec instance may still be reported as running right after its output has ended,
+	// so it is inspected up to maxExecInspects times, doubling the delay between attempts
+	maxExecInspects         = 8
+	defaultExecInspectDelay = 20 * time.Millisecond
+)
+
+var (
+	errDockerAPI        = errors.New("docker API error")
+	errContainerLimits  = errors.New("priority and resource limits are not supported for container jobs")
+	errExitStatus       = errors.New("exit status")
+	errExecStillRunning = errors.New("exec output ended while command is still running")
+)
+
+// jobExecutor runs the commands of jobs with a container target inside that container,
+// and the commands of all other jobs as local processes.
+type jobExecutor struct {
+	local     executor
+	container executor
+}
+
+// dockerExecutor runs commands inside running containers using the Docker Engine API.
+// Canceling a command's context stops waiting for it, but does not stop it inside the container.
+type dockerExecutor struct {
+	socket string
+
+	// delay before inspecting an exec instance again that is still running
+	inspectDelay time.Duration
+}
+
+// dockerExecConfig is the request body to create an exec instance.
+type dockerExecConfig struct {
+	AttachStdout bool     `json:"AttachStdout"`
+	AttachStderr bool     `json:"AttachStderr"`
+	Cmd          []string `json:"Cmd"`
+	Env          []string `json:"Env,omitempty"`
+	WorkingDir   string   `json:"WorkingDir,omitempty"`
+}
+
+func newJobExecutor() jobExecutor {
+	return jobExecutor{
+		local:     processExecutor{},
+		container: dockerExecutor{socket: dockerSocket(), inspectDelay: defaultExecInspectDelay},
+	}
+}
+
+func (e jobExecutor) execute(ctx context.Context, job *cronJobConfiguration, command string, args []string,
+	stdout io.Writer, stderr io.Writer,
+) (int, error) {
+	if job.Container != "" {
+		return e.container.execute(ctx, job, command, args, stdout, stderr)
+	}
+
+	return e.local.execute(ctx, job, command, args, stdout, stderr)
+}
+
+// dockerSocket returns the path of the Docker Engine API socket, taken from DOCKER_HOST if it is a Unix socket.
+func dockerSocket() string {
+	if path, ok := strings.CutPrefix(os.Getenv("DOCKER_HOST"), "unix://"); ok && path != "" {
+		return path
+	}
+
+	return defaultDockerSocket
+}
+
+// execute runs command inside job's container with job's environment and working directory.
+// The command's output is streamed to stdout and stderr, and its exit code is that of the remote command.
+func (e dockerExecutor) execute(ctx context.Context, job *cronJobConfiguration, command string, args []string,
+	stdout io.Writer, stderr io.Writer,
+) (int, error) {
+	if job.hasLimits() {
+		return -1, errContainerLimits
+	}
+
+	client := unixSocketClient(e.socket)
+
+	config := dockerExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          append([]string{command}, args...),
+		Env:          jobEnv(job),
+		WorkingDir:   job.Dir,
+	}
+
+	created := struct {
+		ID string `json:"Id"`
+	}{}
+
+	path := "/containers/" + url.PathEscape(job.Container) + "/exec"
+	if err := dockerCall(ctx, client, http.MethodPost, path, config, &created); err != nil {
+		return -1, fmt.Errorf("create exec in container '%s': %w", job.Container, err)
+	}
+
+	start := map[string]bool{
+		"Detach": false,
+		"Tty":    false,
+	}
+
+	res, err := dockerRequest(ctx, client, http.MethodPost, "/exec/"+url.PathEscape(created.ID)+"/start", start)
+	if err != nil {
+		return -1, fmt.Errorf("start exec in container '%s': %w", job.Container, err)
+	}
+
+	err = demuxDockerStream(res.Body, stdout, stderr)
+
+	_ = res.Body.Close()
+
+	if ctx.Err() != nil {
+		return -1, fmt.Errorf("run: %w", ctx.Err())
+	}
+
+	if err != nil {
+		return -1, fmt.Errorf("read exec output: %w", err)
+	}
+
+	inspect, err := e.inspectExec(ctx, client, created.ID)
+	if err != nil {
+		return -1, err
+	}
+
+	if inspect.ExitCode != 0 {
+		return inspect.ExitCode, fmt.Errorf("run: %w %d", errExitStatus, inspect.ExitCode)
+	}
+
+	return 0, nil
+}
+
+// dockerExecInspect is the state of an exec instance.
+type dockerExecInspect struct {
+	ExitCode int  `json:"ExitCode"`
+	Running  bool `json:"Running"`
+}
+
+// inspectExec returns the state of the exec instance with the given ID once it has stopped running.
+func (e dockerExecutor) inspectExec(ctx context.Context, client *http.Client, id string) (dockerExecInspect, error) {
+	delay := e.inspectDelay
+
+	for attempt := 1; ; attempt++ {
+		inspect := dockerExecInspect{}
+
+		if err := dockerCall(ctx, client, http.MethodGet, "/exec/"+url.PathEscape(id)+"/json", nil, &inspect); err != nil {
+			return inspect, fmt.Errorf("inspect exec: %w", err)
+		}
+
+		if !inspect.Running {
+			return inspect, nil
+		}
+
+		if attempt == maxExecInspects {
+			return inspect, errExecStillRunning
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return inspect, fmt.Errorf("run: %w", ctx.Err())
+
+		case <-timer.C:
+		}
+
+		delay *= 2
+	}
+}
+
+// dockerCall sends a request with body encoded as JSON, and decodes the JSON response into result.
+func dockerCall(ctx context.Context, client *http.Client, method string, path string, body any, result any) error {
+	res, err := dockerRequest(ctx, client, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// dockerRequest sends a request with body encoded as JSON. Responses with an error status are returned as errors.
+func dockerRequest(ctx context.Context, client *http.Client, method string, path string, body any) (*http.Response, error) {
+	var reqBody io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, dockerBaseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connect to Docker: %w", err)
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+
+		msg := struct {
+			Message string `json:"message"`
+		}{}
+
+		if err := json.NewDecoder(res.Body).Decode(&msg); err != nil || msg.Message == "" {
+			msg.Message = res.Status
+		}
+
+		return nil, fmt.Errorf("%w: %s", errDockerAPI, msg.Message)
+	}
+
+	return res, nil
+}
+
+// demuxDockerStream copies a Docker multiplexed stream to stdout and stderr. Every frame of the stream
+// has an 8 byte header containing the stream type and the big-endian length of the payload.
+func demuxDockerStream(stream io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(stream, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("read frame header: %w", err)
+		}
+
+		output := stdout
+		if header[0] == dockerStderr {
+			output = stderr
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+
+		if _, err := io.CopyN(output, stream, size); err != nil {
+			return fmt.Errorf("read frame: %w", err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeDocker is a minimal Docker Engine API server that runs exec instances by returning canned output.
+type fakeDocker struct {
+	container string
+	stdout    string
+	stderr    string
+	exitCode  int
+
+	// number of times the exec instance is reported as running after its output has ended
+	running int
+
+	config   dockerExecConfig
+	inspects int
+}
+
+func (d *fakeDocker) start(t *testing.T) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /containers/{id}/exec", func(w http.ResponseWriter, req *http.Request) {
+		if req.PathValue("id") != d.container {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": "No such container: " + req.PathValue("id")})
+
+			return
+		}
+
+		if err := json.NewDecoder(req.Body).Decode(&d.config); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"Id": "exec1"})
+	})
+
+	mux.HandleFunc("POST /exec/exec1/start", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.docker.multiplexed-stream")
+
+		writeFrame(w, dockerStdout, d.stdout)
+		writeFrame(w, dockerStderr, d.stderr)
+	})
+
+	mux.HandleFunc("GET /exec/exec1/json", func(w http.ResponseWriter, _ *http.Request) {
+		d.inspects++
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"ExitCode": d.exitCode, "Running": d.inspects <= d.running})
+	})
+
+	server := http.Server{Handler: mux}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	return socket
+}
+
+func writeFrame(w http.ResponseWriter, stream byte, payload string) {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+
+	_, _ = w.Write(header)
+	_, _ = w.Write([]byte(payload))
+}
+
+func TestDockerExecutor(t *testing.T) {
+	docker := fakeDocker{
+		container: "pg",
+		stdout:    "VACUUM\n",
+		stderr:    "warning\n",
+		exitCode:  3,
+	}
+
+	exe := dockerExecutor{socket: docker.start(t)}
+
+	job := cronJobConfiguration{
+		Name:      "vacuum",
+		Container: "pg",
+		Dir:       "/tmp",
+		Env:       map[string]string{"PGUSER": "postgres"},
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	code, err := exe.execute(context.Background(), &job, "psql", []string{"-c", "VACUUM ANALYZE"}, &stdout, &stderr)
+	if !errors.Is(err, errExitStatus) {
+		t.Fatalf("err = %v, want exit status error", err)
+	}
+
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+
+	if stdout.String() != "VACUUM\n" || stderr.String() != "warning\n" {
+		t.Errorf("output = %q, %q, want %q, %q", stdout.String(), stderr.String(), "VACUUM\n", "warning\n")
+	}
+
+	want := dockerExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          []string{"psql", "-c", "VACUUM ANALYZE"},
+		Env:          []string{"PGUSER=postgres"},
+		WorkingDir:   "/tmp",
+	}
+
+	if !reflect.DeepEqual(docker.config, want) {
+		t.Errorf("exec config = %+v, want %+v", docker.config, want)
+	}
+}
+
+func TestDockerExecutor_StillRunning(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		running int
+		want    error
+	}{
+		{"stopped", 0, nil},
+		{"stopping", maxExecInspects - 1, nil},
+		{"still running", maxExecInspects, errExecStillRunning},
+	} {
+		docker := fakeDocker{container: "pg", running: test.running}
+
+		exe := dockerExecutor{socket: docker.start(t), inspectDelay: time.Millisecond}
+
+		job := cronJobConfiguration{Name: "vacuum", Container: "pg"}
+
+		if _, err := exe.execute(context.Background(), &job, "true", nil, io.Discard, io.Discard); !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+
+		if docker.inspects != min(test.running+1, maxExecInspects) {
+			t.Errorf("%s: inspected %d times, want %d", test.name, docker.inspects, min(test.running+1, maxExecInspects))
+		}
+	}
+}
+
+func TestDockerExecutor_UnknownContainer(t *testing.T) {
+	docker := fakeDocker{container: "pg"}
+
+	exe := dockerExecutor{socket: docker.start(t)}
+
+	job := cronJobConfiguration{Name: "vacuum", Container: "redis"}
+
+	var output bytes.Buffer
+
+	_, err := exe.execute(context.Background(), &job, "true", nil, &output, &output)
+	if !errors.Is(err, errDockerAPI) {
+		t.Fatalf("err = %v, want Docker API error", err)
+	}
+}
+
+func TestRunCommand_Container(t *testing.T) {
+	docker := fakeDocker{
+		container: "pg",
+		stdout:    "dumped\n",
+	}
+
+	exe := jobExecutor{
+		local:     &fakeExecutor{err: errors.New("must not run locally")},
+		container: dockerExecutor{socket: docker.start(t)},
+	}
+
+	job := cronJobConfiguration{Name: "dump", Container: "pg", Command: "pg_dump"}
+
+	var output bytes.Buffer
+
+	result, err := runCommand(context.Background(), exe, &job, runInfo{}, &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.ExitCode != 0 || output.String() != "dumped\n" {
+		t.Errorf("result = %d, %q, want 0, %q", result.ExitCode, output.String(), "dumped\n")
+	}
+}
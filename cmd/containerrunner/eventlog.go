@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logStdout   = "stdout"
+	logJournald = "journald"
+	logSyslog   = "syslog"
+
+	defaultJournaldSocket = "/run/systemd/journal/socket"
+	defaultSyslogSocket   = "/dev/log"
+
+	// syslog severities, which journald uses as priorities as well
+	logErr     = 3
+	logWarning = 4
+	logNotice  = 5
+	logInfo    = 6
+
+	// syslog facility for system daemons
+	syslogDaemon = 3
+
+	// SD-ID of the structured data in syslog messages, using the enterprise number reserved for documentation
+	syslogSDID = "containerrunner@32473"
+)
+
+var errInvalidLogTarget = errors.New("invalid log target")
+
+// logField is a structured field of a log event, such as JOB or RUN_ID.
+type logField struct {
+	key   string
+	value string
+}
+
+// eventLog writes job events to stdout, to the systemd journal using its native protocol, or to syslog
+// in RFC 5424 format. If writing to the journal or to syslog fails, events are written to stdout instead.
+type eventLog struct {
+	target string
+	conn   net.Conn
+
+	// whether conn is a stream socket, whose syslog messages need to be terminated
+	stream bool
+
+	hostname string
+	tag      string
+
+	mu     sync.Mutex
+	failed bool
+}
+
+// newEventLog returns an event log for target. address is the journal or syslog socket, or a "host:port"
+// UDP address for syslog, and defaults to the usual socket of target if empty. If the socket is missing,
+// the returned event log writes to stdout.
+func newEventLog(target string, address string) (*eventLog, error) {
+	log := eventLog{
+		target: target,
+		tag:    filepath.Base(os.Args[0]),
+	}
+
+	switch target {
+	case "", logStdout:
+		log.target = logStdout
+		return &log, nil
+
+	case logJournald:
+		if address == "" {
+			address = defaultJournaldSocket
+		}
+
+	case logSyslog:
+		if address == "" {
+			address = defaultSyslogSocket
+		}
+
+		log.hostname, _ = os.Hostname()
+
+	default:
+		return nil, fmt.Errorf("%s: %w", target, errInvalidLogTarget)
+	}
+
+	conn, stream, err := dialLog(target, address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("%s: %w, logging to stdout", target, err).Error())
+
+		log.target = logStdout
+
+		return &log, nil
+	}
+
+	log.conn = conn
+	log.stream = stream
+
+	return &log, nil
+}
+
+func dialLog(target string, address string) (net.Conn, bool, error) {
+	if target == logSyslog && !strings.HasPrefix(address, "/") {
+		conn, err := net.Dial("udp", address)
+		return conn, false, err
+	}
+
+	conn, err := net.Dial("unixgram", address)
+	if err == nil || target == logJournald {
+		return conn, false, err
+	}
+
+	// some syslog daemons listen on a stream socket
+	if conn, err := net.Dial("unix", address); err == nil {
+		return conn, true, nil
+	}
+
+	return nil, false, err
+}
+
+// close closes the connection to the journal or to syslog, if any.
+func (l *eventLog) close() {
+	if l.conn != nil {
+		_ = l.conn.Close()
+	}
+}
+
+// log writes an event with message and fields. On stdout, fields are omitted, and errors are written to stderr.
+func (l *eventLog) log(priority int, message string, fields ...logField) {
+	if l.target == logStdout {
+		l.print(priority, message)
+		return
+	}
+
+	var data []byte
+
+	if l.target == logJournald {
+		data = l.journalEntry(priority, message, fields)
+	} else {
+		data = l.syslogMessage(priority, message, fields, time.Now())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.conn.Write(data); err != nil {
+		if !l.failed {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("write to %s: %w, logging to stdout", l.target, err).Error())
+		}
+
+		l.failed = true
+
+		l.print(priority, message)
+
+		return
+	}
+
+	l.failed = false
+}
+
+// output writes the output of a job run. The journal and syslog get an event per line.
+func (l *eventLog) output(output []byte, fields ...logField) {
+	if l.target == logStdout {
+		fmt.Print(string(output))
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+		if line != "" {
+			l.log(logInfo, line, fields...)
+		}
+	}
+}
+
+func (l *eventLog) print(priority int, message string) {
+	out := io.Writer(os.Stdout)
+	if priority <= logErr {
+		out = os.Stderr
+	}
+
+	fmt.Fprintln(out, message)
+}
+
+// journalEntry encodes an entry for the native journal protocol. Values that contain newlines
+// are written with their length instead of as KEY=value lines.
+func (l *eventLog) journalEntry(priority int, message string, fields []logField) []byte {
+	var entry bytes.Buffer
+
+	write := func(key string, value string) {
+		if !strings.Contains(value, "\n") {
+			entry.WriteString(key + "=" + value + "\n")
+			return
+		}
+
+		entry.WriteString(key + "\n")
+		_ = binary.Write(&entry, binary.LittleEndian, uint64(len(value)))
+		entry.WriteString(value + "\n")
+	}
+
+	write("MESSAGE", message)
+	write("PRIORITY", strconv.Itoa(priority))
+	write("SYSLOG_IDENTIFIER", l.tag)
+
+	for _, field := range fields {
+		write(field.key, field.value)
+	}
+
+	return entry.Bytes()
+}
+
+// syslogMessage formats an RFC 5424 message, with fields as structured data.
+func (l *eventLog) syslogMessage(priority int, message string, fields []logField, t time.Time) []byte {
+	hostname := l.hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	data := "-"
+
+	if len(fields) > 0 {
+		params := make([]string, 0, len(fields))
+
+		for _, field := range fields {
+			params = append(params, field.key+`="`+syslogEscaper.Replace(field.value)+`"`)
+		}
+
+		data = "[" + syslogSDID + " " + strings.Join(params, " ") + "]"
+	}
+
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - %s %s", syslogDaemon*8+priority, t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname, l.tag, os.Getpid(), data, message)
+
+	if l.stream {
+		msg += "\n"
+	}
+
+	return []byte(msg)
+}
+
+var syslogEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// jobFields returns the log fields of a run of job.
+func jobFields(job *cronJobConfiguration, record *runRecord, extra ...logField) []logField {
+	fields := []logField{{"JOB", job.Name}}
+
+	if record != nil {
+		fields = append(fields, logField{"RUN_ID", record.ID})
+	}
+
+	return append(fields, extra...)
+}
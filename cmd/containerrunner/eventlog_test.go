@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func listenLog(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "log.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return path, conn
+}
+
+func readLog(t *testing.T, conn *net.UnixConn) []byte {
+	t.Helper()
+
+	buf := make([]byte, 65536)
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	count, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf[:count]
+}
+
+func TestEventLog_Journald(t *testing.T) {
+	path, conn := listenLog(t)
+
+	events, err := newEventLog(logJournald, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.close()
+
+	events.tag = "containerrunner"
+	events.log(logErr, "first\nsecond", logField{"JOB", "backup"}, logField{"EXIT_CODE", "1"})
+
+	var want bytes.Buffer
+
+	want.WriteString("MESSAGE\n")
+	_ = binary.Write(&want, binary.LittleEndian, uint64(len("first\nsecond")))
+	want.WriteString("first\nsecond\nPRIORITY=3\nSYSLOG_IDENTIFIER=containerrunner\nJOB=backup\nEXIT_CODE=1\n")
+
+	if got := readLog(t, conn); !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("entry = %q, want %q", got, want.Bytes())
+	}
+}
+
+func TestEventLog_Syslog(t *testing.T) {
+	path, conn := listenLog(t)
+
+	events, err := newEventLog(logSyslog, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.close()
+
+	events.hostname = "host"
+	events.tag = "containerrunner"
+	events.log(logInfo, "run job 'backup'", logField{"JOB", `a"b]`}, logField{"RUN_ID", "0123"})
+
+	want := regexp.MustCompile(`^<30>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) host containerrunner \d+ - ` +
+		regexp.QuoteMeta(`[containerrunner@32473 JOB="a\"b\]" RUN_ID="0123"] run job 'backup'`) + `$`)
+
+	if got := readLog(t, conn); !want.Match(got) {
+		t.Errorf("message = %q, want match for %s", got, want)
+	}
+}
+
+func TestEventLog_MissingSocket(t *testing.T) {
+	events, err := newEventLog(logJournald, filepath.Join(t.TempDir(), "missing.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if events.target != logStdout {
+		t.Errorf("target = %s, want fallback to %s", events.target, logStdout)
+	}
+
+	if _, err := newEventLog("file", ""); err == nil {
+		t.Error("expected error for invalid log target")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+	formatTOML = "toml"
+)
+
+var (
+	errUnknownConfigFormat = errors.New("unknown configuration format")
+	errTrailingData        = errors.New("unexpected data after configuration")
+	errUnknownField        = errors.New("unknown field")
+)
+
+// yamlLineError matches a single error of a YAML decoding error message.
+var yamlLineError = regexp.MustCompile(`line (\d+): (.*)`)
+
+// configError is an error at a position in a configuration file.
+type configError struct {
+	path string
+	line int
+	col  int
+	err  error
+}
+
+func (e *configError) Error() string {
+	switch {
+	case e.line > 0 && e.col > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.path, e.line, e.col, e.err.Error())
+	case e.line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.path, e.line, e.err.Error())
+	default:
+		return fmt.Sprintf("%s: %s", e.path, e.err.Error())
+	}
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// configFormat returns the format of the configuration file at path. If format is empty,
+// it is chosen by file extension, defaulting to YAML.
+func configFormat(path string, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			return formatJSON, nil
+		case ".toml":
+			return formatTOML, nil
+		default:
+			return formatYAML, nil
+		}
+	}
+
+	switch format = strings.ToLower(format); format {
+	case formatYAML, formatJSON, formatTOML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%s: %w", format, errUnknownConfigFormat)
+	}
+}
+
+// decodeConfig decodes data in the given format into config. Unknown fields are errors.
+// Errors include the position in the file where possible.
+func decodeConfig(path string, format string, data []byte, config *configuration) error {
+	switch format {
+	case formatJSON:
+		return decodeJSON(path, data, config)
+	case formatTOML:
+		return decodeTOML(path, data, config)
+	default:
+		return decodeYAML(path, data, config)
+	}
+}
+
+func decodeYAML(path string, data []byte, config *configuration) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.SetStrict(true)
+
+	err := decoder.Decode(config)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		errs := make([]error, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, yamlError(path, msg))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	return yamlError(path, strings.TrimPrefix(err.Error(), "yaml: "))
+}
+
+// yamlError converts a YAML error message of the form "line N: message" into a configError.
+func yamlError(path string, msg string) error {
+	match := yamlLineError.FindStringSubmatch(msg)
+	if match == nil {
+		return &configError{path: path, err: errors.New(msg)}
+	}
+
+	line, _ := strconv.Atoi(match[1])
+
+	return &configError{path: path, line: line, err: errors.New(match[2])}
+}
+
+func decodeJSON(path string, data []byte, config *configuration) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(config); err != nil {
+		var (
+			syntaxErr *json.SyntaxError
+			typeErr   *json.UnmarshalTypeError
+		)
+
+		offset := decoder.InputOffset()
+
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			offset = typeErr.Offset
+		default:
+			// unknown field errors have no offset, so look for the first use of the field
+			if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+				if name, err := strconv.Unquote(field); err == nil {
+					pattern := regexp.MustCompile(regexp.QuoteMeta(strconv.Quote(name)) + `\s*:`)
+					if loc := pattern.FindIndex(data); loc != nil {
+						offset = int64(loc[0])
+					}
+				}
+			}
+		}
+
+		line, col := offsetPosition(data, offset)
+
+		return &configError{path: path, line: line, col: col, err: errors.New(strings.TrimPrefix(err.Error(), "json: "))}
+	}
+
+	if decoder.More() {
+		line, col := offsetPosition(data, decoder.InputOffset())
+		return &configError{path: path, line: line, col: col, err: errTrailingData}
+	}
+
+	return nil
+}
+
+func decodeTOML(path string, data []byte, config *configuration) error {
+	meta, err := toml.NewDecoder(bytes.NewReader(data)).Decode(config)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return &configError{path: path, line: parseErr.Position.Line, col: parseErr.Position.Col, err: errors.New(parseErr.Message)}
+		}
+
+		return yamlError(path, strings.TrimPrefix(err.Error(), "toml: "))
+	}
+
+	undecoded := meta.Undecoded()
+	if len(undecoded) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0, len(undecoded))
+	for _, key := range undecoded {
+		errs = append(errs, &configError{
+			path: path,
+			line: tomlKeyLine(data, key),
+			err:  fmt.Errorf("%w %s", errUnknownField, key.String()),
+		})
+	}
+
+	return errors.Join(errs...)
+}
+
+// tomlKeyLine returns the line of the first assignment to the last part of key, or 0 if it cannot be found.
+func tomlKeyLine(data []byte, key toml.Key) int {
+	if len(key) == 0 {
+		return 0
+	}
+
+	name := key[len(key)-1]
+
+	for idx, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		for _, prefix := range []string{name, strconv.Quote(name)} {
+			if rest, ok := strings.CutPrefix(line, prefix); ok && strings.HasPrefix(strings.TrimSpace(rest), "=") {
+				return idx + 1
+			}
+		}
+	}
+
+	return 0
+}
+
+// offsetPosition returns the line and column of the byte at offset in data.
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, col
+}
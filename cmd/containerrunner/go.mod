@@ -5,12 +5,12 @@ go 1.22
 toolchain go1.22.1
 
 require (
-	github.com/go-co-op/gocron v1.37.0
+	github.com/BurntSushi/toml v1.6.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
-	go.uber.org/atomic v1.11.0 // indirect
+	github.com/kr/pretty v0.3.0 // indirect
+	github.com/rogpeppe/go-internal v1.8.1 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
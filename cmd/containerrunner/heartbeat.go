@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHeartbeatTimeout = 10 * time.Second
+	defaultHeartbeatRetries = 2
+
+	// maximum size of the output tail sent with the final ping
+	maxHeartbeatBody = 10 * 1024
+
+	// maximum time to wait for pings on shutdown, well within the grace period container runtimes
+	// such as Docker give before killing the process
+	heartbeatShutdownTimeout = 5 * time.Second
+)
+
+var errHeartbeatStatus = errors.New("unexpected status")
+
+// heartbeat sends the pings of a single job run to a healthchecks.io-style monitoring URL:
+// "<url>/start" when the run starts, "<url>" when it succeeds, and "<url>/fail" when it fails,
+// the latter two with the tail of the run's output as the body. A run skipped because a lock
+// is busy neither succeeds nor fails, so it only sends "<url>/log" with the reason.
+// Pings are sent in order in the background, so they never block the run.
+type heartbeat struct {
+	job        string
+	url        string
+	retries    int
+	retryDelay time.Duration
+	client     *http.Client
+
+	pings chan heartbeatPing
+	done  chan struct{}
+}
+
+type heartbeatPing struct {
+	url  string
+	body []byte
+}
+
+// newHeartbeat returns a heartbeat for a run of job, or nil if job has no heartbeat.
+func newHeartbeat(job *cronJobConfiguration) (*heartbeat, error) {
+	if job.Heartbeat == nil || job.Heartbeat.URL == "" {
+		return nil, nil
+	}
+
+	timeout := defaultHeartbeatTimeout
+
+	if job.Heartbeat.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(job.Heartbeat.Timeout); err != nil {
+			return nil, fmt.Errorf("heartbeat timeout '%s': %w", job.Heartbeat.Timeout, err)
+		}
+	}
+
+	retries := defaultHeartbeatRetries
+	if job.Heartbeat.Retries != nil {
+		retries = *job.Heartbeat.Retries
+	}
+
+	beat := heartbeat{
+		job:        job.Name,
+		url:        strings.TrimSuffix(job.Heartbeat.URL, "/"),
+		retries:    retries,
+		retryDelay: time.Second,
+		client:     &http.Client{Timeout: timeout},
+		pings:      make(chan heartbeatPing, 2),
+		done:       make(chan struct{}),
+	}
+
+	go beat.send()
+
+	return &beat, nil
+}
+
+// start sends the start ping.
+func (h *heartbeat) start() {
+	if h == nil {
+		return
+	}
+
+	h.pings <- heartbeatPing{url: h.url + "/start"}
+}
+
+// finish sends the success, failure or log ping, depending on err, with the tail of output as the body.
+// No more pings can be sent afterwards.
+func (h *heartbeat) finish(output []byte, err error) {
+	if h == nil {
+		return
+	}
+
+	url := h.url
+	body := bytes.Clone(output)
+
+	switch {
+	case errors.Is(err, errLockBusy):
+		url += "/log"
+		body = append(body, "\nskipped: "+err.Error()+"\n"...)
+
+	case err != nil:
+		url += "/fail"
+		body = append(body, "\n"+err.Error()+"\n"...)
+	}
+
+	if len(body) > maxHeartbeatBody {
+		body = body[len(body)-maxHeartbeatBody:]
+	}
+
+	h.pings <- heartbeatPing{url: url, body: body}
+
+	close(h.pings)
+}
+
+// wait waits until all pings have been sent.
+func (h *heartbeat) wait() {
+	if h == nil {
+		return
+	}
+
+	<-h.done
+}
+
+// waitHeartbeat makes the runner wait for the pings of beat to be sent on shutdown.
+func (r *runner) waitHeartbeat(beat *heartbeat) {
+	if beat == nil {
+		return
+	}
+
+	r.heartbeats.Add(1)
+
+	go func() {
+		defer r.heartbeats.Done()
+
+		beat.wait()
+	}()
+}
+
+// waitHeartbeats waits for the pings of all runs to be sent, or until timeout has passed,
+// and returns whether all pings have been sent.
+func (r *runner) waitHeartbeats(timeout time.Duration) bool {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		r.heartbeats.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+
+	case <-timer.C:
+		fmt.Fprintln(os.Stderr, fmt.Errorf("heartbeat: pings not sent within %s of shutdown", timeout).Error())
+		return false
+	}
+}
+
+func (h *heartbeat) send() {
+	defer close(h.done)
+
+	for ping := range h.pings {
+		if err := h.ping(ping); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': heartbeat: %w", h.job, err).Error())
+		}
+	}
+}
+
+func (h *heartbeat) ping(ping heartbeatPing) error {
+	var err error
+
+	for attempt := 0; attempt <= h.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * h.retryDelay)
+		}
+
+		if err = h.post(ping); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (h *heartbeat) post(ping heartbeatPing) error {
+	res, err := h.client.Post(ping.url, "text/plain; charset=utf-8", bytes.NewReader(ping.body))
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("ping %s: %w: %s", ping.url, errHeartbeatStatus, res.Status)
+	}
+
+	return nil
+}
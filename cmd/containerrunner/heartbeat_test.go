@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// heartbeatServer is a local stand-in for a monitoring service that records received pings.
+type heartbeatServer struct {
+	mu       sync.Mutex
+	pings    []string
+	bodies   []string
+	failures int
+}
+
+func (s *heartbeatServer) start(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		s.pings = append(s.pings, req.URL.Path)
+		s.bodies = append(s.bodies, string(body))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestHeartbeat(t *testing.T) {
+	server := heartbeatServer{}
+	url := server.start(t) + "/ping/abc"
+
+	for _, test := range []struct {
+		name     string
+		err      error
+		wantPath string
+	}{
+		{"success", nil, "/ping/abc"},
+		{"failure", errors.New("run: exit status 1"), "/ping/abc/fail"},
+		{"skipped", fmt.Errorf("lock 'db': %w", errLockBusy), "/ping/abc/log"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server.pings, server.bodies = nil, nil
+
+			beat, err := newHeartbeat(&cronJobConfiguration{Name: "job", Heartbeat: &heartbeatConfiguration{URL: url}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			beat.start()
+			beat.finish([]byte("output\n"), test.err)
+			beat.wait()
+
+			if len(server.pings) != 2 || server.pings[0] != "/ping/abc/start" || server.pings[1] != test.wantPath {
+				t.Fatalf("pings = %v, want [/ping/abc/start %s]", server.pings, test.wantPath)
+			}
+
+			if !strings.HasPrefix(server.bodies[1], "output\n") {
+				t.Errorf("body = %q, want output tail", server.bodies[1])
+			}
+		})
+	}
+}
+
+func TestHeartbeat_Retries(t *testing.T) {
+	server := heartbeatServer{failures: 2}
+	retries := 2
+
+	beat, err := newHeartbeat(&cronJobConfiguration{
+		Name:      "job",
+		Heartbeat: &heartbeatConfiguration{URL: server.start(t), Retries: &retries},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	beat.retryDelay = 0
+
+	beat.start()
+	beat.finish(nil, nil)
+	beat.wait()
+
+	if len(server.pings) != 2 {
+		t.Fatalf("pings = %v, want start and success after retries", server.pings)
+	}
+}
+
+func TestRunner_RunJobHeartbeatUnreachable(t *testing.T) {
+	run := newRunner(1024)
+	run.executor = &fakeExecutor{output: "ok\n"}
+
+	// nothing listens on this URL, which must not affect the run
+	job := cronJobConfiguration{
+		Name:      "job",
+		Command:   "true",
+		Heartbeat: &heartbeatConfiguration{URL: "http://127.0.0.1:1/ping", Timeout: "100ms"},
+	}
+
+	run.setJobs(nil, []*cronJobConfiguration{&job})
+	run.runJob(context.Background(), &job)
+
+	history := run.jobHistory("job")
+	if len(history) != 1 || history[0].Error != "" {
+		t.Fatalf("history = %+v, want a successful run", history)
+	}
+}
+
+func TestRunner_RunJobHeartbeatOutputTail(t *testing.T) {
+	server := heartbeatServer{}
+
+	run := newRunner(16)
+	run.executor = &fakeExecutor{output: strings.Repeat("x", 100) + "tail\n"}
+
+	job := cronJobConfiguration{
+		Name:      "job",
+		Command:   "true",
+		Heartbeat: &heartbeatConfiguration{URL: server.start(t)},
+	}
+
+	run.setJobs(nil, []*cronJobConfiguration{&job})
+	run.runJob(context.Background(), &job)
+	run.heartbeats.Wait()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	// the body is limited to the run's output buffer
+	if len(server.bodies) != 2 || server.bodies[1] != "xxxxxxxxxxxtail\n" {
+		t.Fatalf("bodies = %q, want the last 16 bytes of output", server.bodies)
+	}
+}
+
+func TestRunner_WaitHeartbeats(t *testing.T) {
+	run := newRunner(1024)
+
+	run.heartbeats.Add(1)
+
+	// pings that can't be sent in time don't block shutdown
+	if run.waitHeartbeats(10 * time.Millisecond) {
+		t.Error("waited for pending pings")
+	}
+
+	run.heartbeats.Done()
+
+	if !run.waitHeartbeats(time.Second) {
+		t.Error("pings not sent")
+	}
+}
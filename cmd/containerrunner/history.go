@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// maxHistory is the number of runs kept per job.
+const maxHistory = 20
+
+// runRecord records a single run of a job.
+type runRecord struct {
+	ID       string            `json:"id"`
+	Job      string            `json:"job"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end,omitempty"`
+	ExitCode int               `json:"exit_code"`
+	Error    string            `json:"error,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	Deferred string            `json:"deferred,omitempty"`
+	Skipped  string            `json:"skipped,omitempty"`
+
+	// ID of the process that is running, or ran last, for the run
+	PID int `json:"pid,omitempty"`
+
+	output *outputBuffer
+}
+
+func newRunID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// startRun adds a new run of job to the history.
+func (r *runner) startRun(job *cronJobConfiguration, info runInfo) *runRecord {
+	record := runRecord{
+		ID:       newRunID(),
+		Job:      job.Name,
+		Start:    r.clock.Now(),
+		ExitCode: -1,
+		Deferred: info.deferred,
+		output:   newOutputBuffer(r.outputBufferSize),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := append(r.history[job.Name], &record)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	r.history[job.Name] = history
+
+	return &record
+}
+
+// finishRun records the result of a run, counts consecutive failures of its job, and ends following
+// the run's output. Runs skipped because a lock is busy are neither failures nor successes.
+func (r *runner) finishRun(record *runRecord, result *runResult, err error) {
+	defer record.output.close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record.End = r.clock.Now()
+	record.ExitCode = result.ExitCode
+
+	if len(result.Details) > 0 {
+		record.Details = result.Details
+	}
+
+	switch {
+	case errors.Is(err, errLockBusy):
+		record.Skipped = err.Error()
+
+	case err != nil:
+		record.Error = err.Error()
+		r.failures[record.Job]++
+
+	default:
+		delete(r.failures, record.Job)
+	}
+}
+
+// runOutput returns the output buffer of the run of the named job with the given ID.
+func (r *runner) runOutput(name string, id string) (*outputBuffer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range r.history[name] {
+		if record.ID == id {
+			return record.output, true
+		}
+	}
+
+	return nil, false
+}
+
+// currentRunOutput returns the output buffer of the latest run of the named job that has not finished yet.
+func (r *runner) currentRunOutput(name string) (*outputBuffer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	history := r.history[name]
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].End.IsZero() {
+			return history[i].output, true
+		}
+	}
+
+	return nil, false
+}
+
+// jobHistory returns copies of the recorded runs of the named job, oldest first.
+func (r *runner) jobHistory(name string) []runRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := make([]runRecord, 0, len(r.history[name]))
+	for _, record := range r.history[name] {
+		records = append(records, *record)
+	}
+
+	return records
+}
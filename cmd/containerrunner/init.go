@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInitWaitTimeout = time.Minute
+	initWaitInterval       = time.Second
+	initWaitAttemptTimeout = 5 * time.Second
+
+	onFailureAbort    = "abort"
+	onFailureContinue = "continue"
+)
+
+var (
+	errInitTaskKind        = errors.New("init task must have either a wait condition or a command")
+	errInitTaskOnFailure   = errors.New("unknown on_failure value")
+	errInitWaitEmpty       = errors.New("wait condition is empty")
+	errHTTPStatus          = errors.New("unexpected HTTP status")
+	errInitTaskFailed      = errors.New("init task failed")
+	errFileDoesNotExistYet = errors.New("file does not exist")
+)
+
+// runInit runs all init tasks in order. A failed task aborts the remaining tasks, unless it
+// is configured to continue on failure.
+func runInit(ctx context.Context, tasks []*initTaskConfiguration) error {
+	for idx, task := range tasks {
+		name := task.name(idx)
+
+		fmt.Printf("init task '%s': start\n", name)
+
+		if err := runInitTask(ctx, task); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("init task '%s': %w", name, ctx.Err())
+			}
+
+			if task.OnFailure == onFailureContinue {
+				fmt.Fprintln(os.Stderr, fmt.Errorf("init task '%s': %w, continuing", name, err).Error())
+				continue
+			}
+
+			return fmt.Errorf("init task '%s': %w: %w", name, errInitTaskFailed, err)
+		}
+
+		fmt.Printf("init task '%s': done\n", name)
+	}
+
+	return nil
+}
+
+func runInitTask(ctx context.Context, task *initTaskConfiguration) error {
+	if err := task.validate(); err != nil {
+		return err
+	}
+
+	if task.Wait != nil {
+		timeout := defaultInitWaitTimeout
+
+		if task.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(task.Timeout); err != nil {
+				return fmt.Errorf("timeout '%s': %w", task.Timeout, err)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return waitFor(ctx, task.Wait)
+	}
+
+	job := task.job()
+
+	var output strings.Builder
+
+	_, err := runCommand(ctx, processExecutor{}, job, runInfo{}, &output)
+
+	fmt.Print(output.String())
+
+	return err
+}
+
+// waitFor polls until all conditions of wait are met or ctx is done.
+func waitFor(ctx context.Context, wait *waitConfiguration) error {
+	ticker := time.NewTicker(initWaitInterval)
+	defer ticker.Stop()
+
+	for {
+		err := checkWait(ctx, wait)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkWait(ctx context.Context, wait *waitConfiguration) error {
+	ctx, cancel := context.WithTimeout(ctx, initWaitAttemptTimeout)
+	defer cancel()
+
+	if wait.TCP != "" {
+		var dialer net.Dialer
+
+		conn, err := dialer.DialContext(ctx, "tcp", wait.TCP)
+		if err != nil {
+			return fmt.Errorf("tcp %s: %w", wait.TCP, err)
+		}
+
+		_ = conn.Close()
+	}
+
+	if wait.HTTP != "" {
+		if err := checkHTTP(ctx, wait.HTTP); err != nil {
+			return fmt.Errorf("http %s: %w", wait.HTTP, err)
+		}
+	}
+
+	if wait.File != "" {
+		path := expandHome(wait.File)
+
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%s: %w", path, errFileDoesNotExistYet)
+			}
+
+			return fmt.Errorf("file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func checkHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s: %w", res.Status, errHTTPStatus)
+	}
+
+	return nil
+}
+
+// validateInit checks the settings of all init tasks, so that invalid ones are rejected
+// when the configuration is loaded rather than when they run.
+func (c *configuration) validateInit() error {
+	for idx, task := range c.Init {
+		if err := task.validate(); err != nil {
+			return fmt.Errorf("init task '%s': %w", task.name(idx), err)
+		}
+	}
+
+	return nil
+}
+
+func (t *initTaskConfiguration) validate() error {
+	if (t.Wait == nil) == (t.Command == "") {
+		return errInitTaskKind
+	}
+
+	if t.Wait != nil && t.Wait.TCP == "" && t.Wait.HTTP == "" && t.Wait.File == "" {
+		return errInitWaitEmpty
+	}
+
+	switch t.OnFailure {
+	case "", onFailureAbort, onFailureContinue:
+	default:
+		return fmt.Errorf("%s: %w", t.OnFailure, errInitTaskOnFailure)
+	}
+
+	if t.Timeout != "" {
+		if _, err := time.ParseDuration(t.Timeout); err != nil {
+			return fmt.Errorf("timeout '%s': %w", t.Timeout, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *initTaskConfiguration) name(idx int) string {
+	switch {
+	case t.Name != "":
+		return t.Name
+	case t.Command != "":
+		return filepath.Base(t.Command)
+	case t.Wait != nil:
+		return "wait for " + t.Wait.String()
+	default:
+		return fmt.Sprintf("#%d", idx+1)
+	}
+}
+
+func (w *waitConfiguration) String() string {
+	conditions := []string{}
+
+	if w.TCP != "" {
+		conditions = append(conditions, "tcp "+w.TCP)
+	}
+
+	if w.HTTP != "" {
+		conditions = append(conditions, "http "+w.HTTP)
+	}
+
+	if w.File != "" {
+		conditions = append(conditions, "file "+w.File)
+	}
+
+	return strings.Join(conditions, ", ")
+}
+
+func (t *initTaskConfiguration) job() *cronJobConfiguration {
+	return &cronJobConfiguration{
+		Name:    t.Name,
+		Command: t.Command,
+		Args:    t.Args,
+		Env:     t.Env,
+		Dir:     t.Dir,
+		Timeout: t.Timeout,
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckWait(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	// a port that nothing listens on anymore
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "ready")
+
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	for _, test := range []struct {
+		name    string
+		wait    waitConfiguration
+		wantErr bool
+		want    error
+	}{
+		{"tcp", waitConfiguration{TCP: listener.Addr().String()}, false, nil},
+		{"tcp closed", waitConfiguration{TCP: closedAddr}, true, nil},
+		{"http", waitConfiguration{HTTP: server.URL + "/ready"}, false, nil},
+		{"http unavailable", waitConfiguration{HTTP: server.URL + "/starting"}, true, errHTTPStatus},
+		{"file", waitConfiguration{File: file}, false, nil},
+		{"file missing", waitConfiguration{File: missing}, true, errFileDoesNotExistYet},
+		{"all", waitConfiguration{TCP: listener.Addr().String(), HTTP: server.URL + "/ready", File: file}, false, nil},
+		{"all but one", waitConfiguration{TCP: listener.Addr().String(), HTTP: server.URL + "/ready", File: missing}, true, errFileDoesNotExistYet},
+	} {
+		err := checkWait(context.Background(), &test.wait)
+
+		if (err != nil) != test.wantErr || (test.want != nil && !errors.Is(err, test.want)) {
+			t.Errorf("%s: err = %v, want error %t (%v)", test.name, err, test.wantErr, test.want)
+		}
+	}
+}
+
+func TestRunInit(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+
+	for _, test := range []struct {
+		name       string
+		first      initTaskConfiguration
+		wantErr    []error
+		wantMarker bool
+	}{
+		{"success", initTaskConfiguration{Command: "true"}, nil, true},
+		{"abort on failure", initTaskConfiguration{Command: "false"}, []error{errInitTaskFailed}, false},
+		{"abort explicitly", initTaskConfiguration{Command: "false", OnFailure: onFailureAbort}, []error{errInitTaskFailed}, false},
+		{"continue on failure", initTaskConfiguration{Command: "false", OnFailure: onFailureContinue}, nil, true},
+		{
+			"command timeout",
+			initTaskConfiguration{Command: "sleep", Args: []string{"5"}, Timeout: "50ms"},
+			[]error{errInitTaskFailed, errJobTimeout},
+			false,
+		},
+		{
+			"wait timeout",
+			initTaskConfiguration{Wait: &waitConfiguration{File: filepath.Join(dir, "missing")}, Timeout: "50ms"},
+			[]error{errInitTaskFailed, context.DeadlineExceeded, errFileDoesNotExistYet},
+			false,
+		},
+		{
+			"wait timeout, continue",
+			initTaskConfiguration{Wait: &waitConfiguration{File: filepath.Join(dir, "missing")}, Timeout: "50ms", OnFailure: onFailureContinue},
+			nil,
+			true,
+		},
+	} {
+		_ = os.Remove(marker)
+
+		tasks := []*initTaskConfiguration{&test.first, {Command: "touch", Args: []string{marker}}}
+
+		err := runInit(context.Background(), tasks)
+
+		if (err != nil) != (len(test.wantErr) > 0) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.wantErr)
+		}
+
+		for _, want := range test.wantErr {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: err = %v, want %v", test.name, err, want)
+			}
+		}
+
+		if _, err := os.Stat(marker); (err == nil) != test.wantMarker {
+			t.Errorf("%s: next task ran = %t, want %t", test.name, err == nil, test.wantMarker)
+		}
+	}
+}
+
+func TestRunInit_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	task := initTaskConfiguration{Wait: &waitConfiguration{File: filepath.Join(t.TempDir(), "missing")}, OnFailure: onFailureContinue}
+
+	// canceling aborts even tasks that continue on failure
+	if err := runInit(ctx, []*initTaskConfiguration{&task}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
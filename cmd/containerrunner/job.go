@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var errJobTimeout = errors.New("timeout exceeded")
+
+// runResult is the result of a job run.
+type runResult struct {
+	ExitCode int
+
+	// Details contains additional information about the run, such as backup statistics.
+	Details map[string]string
+}
+
+// executor runs a command of a job.
+type executor interface {
+	// execute runs command and returns its exit code, which is only meaningful if the command could be started.
+	execute(ctx context.Context, job *cronJobConfiguration, command string, args []string, stdout io.Writer, stderr io.Writer) (int, error)
+}
+
+// processExecutor runs commands as local processes.
+type processExecutor struct{}
+
+type pidReporterKey struct{}
+
+// withPIDReporter returns a context that makes executors call report with the ID of every local process they start.
+func withPIDReporter(ctx context.Context, report func(pid int)) context.Context {
+	return context.WithValue(ctx, pidReporterKey{}, report)
+}
+
+func reportPID(ctx context.Context, pid int) {
+	if report, ok := ctx.Value(pidReporterKey{}).(func(pid int)); ok {
+		report(pid)
+	}
+}
+
+// runCommand runs job in the foreground using exe, writing its combined output to output.
+// The job's processes get environment variables that describe the run given by info.
+// The result's exit code is only meaningful if the job's command could be started.
+func runCommand(ctx context.Context, exe executor, job *cronJobConfiguration, info runInfo, output io.Writer,
+) (*runResult, error) {
+	result := runResult{
+		ExitCode: -1,
+		Details:  map[string]string{},
+	}
+
+	timeout, err := job.timeout()
+	if err != nil {
+		return &result, err
+	}
+
+	outcome, err := newOutcomeRules(job)
+	if err != nil {
+		return &result, err
+	}
+
+	job, mask, err := resolveSecrets(ctx, job)
+	if err != nil {
+		return &result, fmt.Errorf("secrets: %w", err)
+	}
+
+	job = job.withRunEnv(info)
+
+	output, flushOutput := mask.writer(output)
+	defer flushOutput()
+
+	output = outcome.writer(output)
+
+	releaseLocks, err := acquireLocks(ctx, job)
+	if err != nil {
+		return &result, err
+	}
+	defer releaseLocks()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if job.Backup != nil {
+		err = runBackup(ctx, exe, job, output, &result)
+	} else {
+		result.ExitCode, err = exe.execute(ctx, job, job.Command, job.Args, output, output)
+	}
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &result, fmt.Errorf("%s: %w", job.Timeout, errJobTimeout)
+	}
+
+	err = outcome.decide(&result, err)
+
+	mask.maskDetails(result.Details)
+
+	return &result, mask.maskError(err)
+}
+
+// execute runs command as a process with job's environment, working directory and limits.
+func (processExecutor) execute(ctx context.Context, job *cronJobConfiguration, command string, args []string,
+	stdout io.Writer, stderr io.Writer,
+) (int, error) {
+	cmd := jobCommand(ctx, job, command, args)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	limits, err := limitCommand(cmd, job)
+	if err != nil {
+		return -1, fmt.Errorf("limits: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		limits.started()
+		_ = limits.err()
+
+		return -1, fmt.Errorf("start: %w", err)
+	}
+
+	limits.started()
+
+	reportPID(ctx, cmd.Process.Pid)
+
+	err = cmd.Wait()
+
+	if limitErr := limits.err(); limitErr != nil {
+		return exitCode(err), limitErr
+	}
+
+	if err != nil {
+		return exitCode(err), fmt.Errorf("run: %w", err)
+	}
+
+	return 0, nil
+}
+
+func jobCommand(ctx context.Context, job *cronJobConfiguration, command string, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = job.Dir
+	cmd.Env = append(os.Environ(), jobEnv(job)...)
+	cmd.WaitDelay = 10 * time.Second
+
+	return cmd
+}
+
+func jobEnv(job *cronJobConfiguration) []string {
+	env := make([]string, 0, len(job.Env))
+	for key, value := range job.Env {
+		env = append(env, key+"="+value)
+	}
+
+	sort.Strings(env)
+
+	return env
+}
+
+// withRunEnv returns a copy of job whose environment describes the run given by info,
+// or job itself for commands that are not job runs.
+func (job *cronJobConfiguration) withRunEnv(info runInfo) *cronJobConfiguration {
+	if info.trigger == "" {
+		return job
+	}
+
+	run := *job
+	run.Env = make(map[string]string, len(job.Env)+4)
+
+	for key, value := range job.Env {
+		run.Env[key] = value
+	}
+
+	run.Env["CONTAINERRUNNER_JOB"] = job.Name
+	run.Env["CONTAINERRUNNER_RUN_ID"] = info.id
+	run.Env["CONTAINERRUNNER_SCHEDULED_AT"] = info.scheduledAt.Format(time.RFC3339)
+	run.Env["CONTAINERRUNNER_TRIGGER"] = info.trigger
+
+	return &run
+}
+
+func (job *cronJobConfiguration) timeout() (time.Duration, error) {
+	if job.Timeout == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(job.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("timeout '%s': %w", job.Timeout, err)
+	}
+
+	return timeout, nil
+}
+
+// exitCode returns the exit code of a finished command, using the shell convention of 128+n
+// for commands terminated by signal n.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return exitErr.ExitCode()
+}
+
+func formatCommand(command string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+
+	for _, word := range append([]string{command}, args...) {
+		if word == "" || strings.ContainsAny(word, " \t\n\"'\\$`*?[]{}()<>|&;#~") {
+			word = "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+		}
+
+		words = append(words, word)
+	}
+
+	return strings.Join(words, " ")
+}
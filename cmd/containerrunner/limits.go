@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	execLimitedCommand = "exec-limited"
+
+	// exit code of the exec-limited helper if limits could not be applied
+	execLimitedFailedCode = 126
+
+	ioprioClassShift = 13
+	ioprioWhoProcess = 1
+)
+
+var (
+	errApplyLimits   = errors.New("apply limits")
+	errInvalidIONice = errors.New("invalid ionice value")
+	errInvalidSize   = errors.New("invalid size")
+	errNoCommand     = errors.New("no command")
+)
+
+var ioprioClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// limitReport receives the error of the exec-limited helper if it cannot apply limits.
+type limitReport struct {
+	reader *os.File
+	writer *os.File
+}
+
+func (job *cronJobConfiguration) hasLimits() bool {
+	return job.Nice != nil || job.IONice != "" || job.Limits != nil
+}
+
+// limitArgs returns the exec-limited helper flags for job's priority and resource limits.
+func (job *cronJobConfiguration) limitArgs() ([]string, error) {
+	args := []string{}
+
+	if job.Nice != nil {
+		args = append(args, "-nice", strconv.Itoa(*job.Nice))
+	}
+
+	if job.IONice != "" {
+		if _, err := parseIONice(job.IONice); err != nil {
+			return nil, err
+		}
+
+		args = append(args, "-ionice", job.IONice)
+	}
+
+	if job.Limits == nil {
+		return args, nil
+	}
+
+	if job.Limits.OpenFiles > 0 {
+		args = append(args, "-nofile", strconv.FormatUint(job.Limits.OpenFiles, 10))
+	}
+
+	if job.Limits.AddressSpace != "" {
+		size, err := parseSize(job.Limits.AddressSpace)
+		if err != nil {
+			return nil, fmt.Errorf("address space: %w", err)
+		}
+
+		args = append(args, "-as", strconv.FormatUint(size, 10))
+	}
+
+	if job.Limits.CPUTime != "" {
+		cpu, err := time.ParseDuration(job.Limits.CPUTime)
+		if err != nil {
+			return nil, fmt.Errorf("cpu time '%s': %w", job.Limits.CPUTime, err)
+		}
+
+		args = append(args, "-cpu", strconv.FormatInt(int64((cpu+time.Second-1)/time.Second), 10))
+	}
+
+	return args, nil
+}
+
+// limitCommand rewrites cmd to start its command through the exec-limited helper,
+// which applies job's priority and resource limits to itself before executing the command.
+// It returns nil if job has no limits.
+func limitCommand(cmd *exec.Cmd, job *cronJobConfiguration) (*limitReport, error) {
+	// the command has been looked up like any other, so leave it to fail to start the same way
+	if !job.hasLimits() || cmd.Err != nil {
+		return nil, nil
+	}
+
+	limitArgs, err := job.limitArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("find executable: %w", err)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("pipe: %w", err)
+	}
+
+	args := append([]string{self, execLimitedCommand}, limitArgs...)
+	args = append(args, "--", cmd.Path)
+	args = append(args, cmd.Args[1:]...)
+
+	cmd.Path = self
+	cmd.Args = args
+	cmd.ExtraFiles = append(cmd.ExtraFiles, writer)
+
+	return &limitReport{
+		reader: reader,
+		writer: writer,
+	}, nil
+}
+
+// started must be called after the command has been started.
+func (l *limitReport) started() {
+	if l == nil {
+		return
+	}
+
+	_ = l.writer.Close()
+}
+
+// err returns the error reported by the exec-limited helper, if any.
+// It must be called after the command has finished.
+func (l *limitReport) err() error {
+	if l == nil {
+		return nil
+	}
+
+	defer l.reader.Close()
+
+	// the report is a single line, so stop there rather than waiting for processes that still hold the pipe
+	msg, err := bufio.NewReader(l.reader).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read limit report: %w", err)
+	}
+
+	if len(msg) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errApplyLimits, strings.TrimSpace(string(msg)))
+}
+
+// execLimitedCmd implements the hidden "exec-limited" subcommand. It applies priority and
+// resource limits to its own process and then replaces itself with the actual command.
+// Errors are reported through file descriptor 3 if it is open.
+func execLimitedCmd(args []string) int {
+	// priorities are per thread, so make sure they are applied to the thread that calls exec
+	runtime.LockOSThread()
+
+	report := os.NewFile(3, "limit-report")
+
+	if err := execLimited(args); err != nil {
+		msg := err.Error() + "\n"
+
+		if report == nil || writeReport(report, msg) != nil {
+			fmt.Fprint(os.Stderr, msg)
+		}
+
+		return execLimitedFailedCode
+	}
+
+	return 0
+}
+
+func writeReport(report *os.File, msg string) error {
+	if _, err := report.WriteString(msg); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	return nil
+}
+
+func execLimited(args []string) error {
+	flags := flag.NewFlagSet(execLimitedCommand, flag.ContinueOnError)
+
+	var (
+		nice   int
+		ionice string
+		nofile uint64
+		as     uint64
+		cpu    uint64
+	)
+
+	niceSet := false
+
+	flags.Func("nice", "niceness", func(value string) error {
+		niceSet = true
+
+		var err error
+		nice, err = strconv.Atoi(value)
+
+		return err
+	})
+
+	flags.StringVar(&ionice, "ionice", ionice, "I/O scheduling class and level")
+	flags.Uint64Var(&nofile, "nofile", nofile, "max open files")
+	flags.Uint64Var(&as, "as", as, "max address space in bytes")
+	flags.Uint64Var(&cpu, "cpu", cpu, "max CPU time in seconds")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+
+	if flags.NArg() == 0 {
+		return errNoCommand
+	}
+
+	if niceSet {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, nice); err != nil {
+			return fmt.Errorf("set nice %d: %w", nice, err)
+		}
+	}
+
+	if ionice != "" {
+		if err := setIOPrio(ionice); err != nil {
+			return fmt.Errorf("set ionice %s: %w", ionice, err)
+		}
+	}
+
+	for _, limit := range []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"max open files", syscall.RLIMIT_NOFILE, nofile},
+		{"max address space", syscall.RLIMIT_AS, as},
+		{"max CPU time", syscall.RLIMIT_CPU, cpu},
+	} {
+		if limit.value == 0 {
+			continue
+		}
+
+		rlimit := syscall.Rlimit{Cur: limit.value, Max: limit.value}
+		if err := syscall.Setrlimit(limit.resource, &rlimit); err != nil {
+			return fmt.Errorf("set %s to %d: %w", limit.name, limit.value, err)
+		}
+	}
+
+	path, err := exec.LookPath(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("look up command: %w", err)
+	}
+
+	// the report pipe is only for the helper, so the command and its children must not inherit it
+	syscall.CloseOnExec(3)
+
+	if err := syscall.Exec(path, flags.Args(), os.Environ()); err != nil {
+		return fmt.Errorf("exec %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func setIOPrio(ionice string) error {
+	prio, err := parseIONice(ionice)
+	if err != nil {
+		return err
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio)); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// parseIONice parses an I/O priority in the form "class[:level]", for example "idle" or "best-effort:7".
+func parseIONice(ionice string) (int, error) {
+	name, levelStr, hasLevel := strings.Cut(ionice, ":")
+
+	class, ok := ioprioClasses[name]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", ionice, errInvalidIONice)
+	}
+
+	level := 0
+
+	if hasLevel {
+		var err error
+		if level, err = strconv.Atoi(levelStr); err != nil || level < 0 || level > 7 {
+			return 0, fmt.Errorf("%s: %w", ionice, errInvalidIONice)
+		}
+	}
+
+	return class<<ioprioClassShift | level, nil
+}
+
+// parseSize parses a size in bytes with an optional binary suffix, for example "512M" or "4G".
+func parseSize(size string) (uint64, error) {
+	number := size
+	multiplier := uint64(1)
+
+	for idx, suffix := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(strings.ToUpper(number), suffix) {
+			multiplier = 1 << (10 * (idx + 1))
+			number = number[:len(number)-1]
+
+			break
+		}
+	}
+
+	value, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || value == 0 || value > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("%s: %w", size, errInvalidSize)
+	}
+
+	return value * multiplier, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for _, test := range []struct {
+		size string
+		want uint64
+	}{
+		{"512", 512},
+		{"4K", 4 << 10},
+		{"4k", 4 << 10},
+		{"512M", 512 << 20},
+		{"2G", 2 << 30},
+		{"1T", 1 << 40},
+		{"16777215T", 16777215 << 40},
+	} {
+		got, err := parseSize(test.size)
+		if err != nil || got != test.want {
+			t.Errorf("parseSize(%s) = %d, %v, want %d", test.size, got, err, test.want)
+		}
+	}
+
+	for _, size := range []string{"", "0", "0K", "-1", "1.5G", "G", "4X", "16777216T", "18446744073709551615K"} {
+		if _, err := parseSize(size); !errors.Is(err, errInvalidSize) {
+			t.Errorf("parseSize(%s): err = %v, want %v", size, err, errInvalidSize)
+		}
+	}
+}
+
+func TestLimitArgs(t *testing.T) {
+	nice := 10
+
+	for _, test := range []struct {
+		name string
+		job  cronJobConfiguration
+		want []string
+		err  error
+	}{
+		{"none", cronJobConfiguration{}, []string{}, nil},
+		{"nice", cronJobConfiguration{Nice: &nice}, []string{"-nice", "10"}, nil},
+		{"ionice", cronJobConfiguration{IONice: "best-effort:7"}, []string{"-ionice", "best-effort:7"}, nil},
+		{"invalid ionice", cronJobConfiguration{IONice: "best-effort:8"}, nil, errInvalidIONice},
+		{
+			"limits",
+			cronJobConfiguration{Limits: &limitsConfiguration{OpenFiles: 64, AddressSpace: "1G", CPUTime: "1500ms"}},
+			[]string{"-nofile", "64", "-as", "1073741824", "-cpu", "2"},
+			nil,
+		},
+		{"invalid size", cronJobConfiguration{Limits: &limitsConfiguration{AddressSpace: "lots"}}, nil, errInvalidSize},
+	} {
+		got, err := test.job.limitArgs()
+		if !errors.Is(err, test.err) || (test.err == nil && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("%s: limit args = %v, %v, want %v, %v", test.name, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestProcessExecutor_Limits(t *testing.T) {
+	nice := 5
+
+	job := cronJobConfiguration{
+		Name:   "job",
+		Nice:   &nice,
+		Limits: &limitsConfiguration{OpenFiles: 64},
+	}
+
+	var output strings.Builder
+
+	code, err := processExecutor{}.execute(context.Background(), &job, "sh", []string{"-c", "nice; ulimit -n"}, &output, &output)
+	if err != nil || code != 0 {
+		t.Fatalf("code = %d, err = %v, output = %q", code, err, output.String())
+	}
+
+	if output.String() != "5\n64\n" {
+		t.Errorf("output = %q, want niceness 5 and 64 open files", output.String())
+	}
+}
+
+func TestProcessExecutor_LimitsMissingCommand(t *testing.T) {
+	nice := 5
+
+	_, plainErr := processExecutor{}.execute(context.Background(), &cronJobConfiguration{Name: "job"},
+		"containerrunner-missing-command", nil, &strings.Builder{}, &strings.Builder{})
+
+	code, err := processExecutor{}.execute(context.Background(), &cronJobConfiguration{Name: "job", Nice: &nice},
+		"containerrunner-missing-command", nil, &strings.Builder{}, &strings.Builder{})
+
+	// a missing command fails to start with and without limits alike
+	if plainErr == nil || err == nil || err.Error() != plainErr.Error() || code != -1 {
+		t.Errorf("code = %d, err = %v, want -1, %v", code, err, plainErr)
+	}
+}
+
+func TestValidate_ContainerLimits(t *testing.T) {
+	nice := 5
+
+	job := cronJobConfiguration{Name: "job", Command: "true", Container: "db", Nice: &nice}
+
+	if err := job.validate(nil); !errors.Is(err, errContainerLimits) {
+		t.Errorf("err = %v, want %v", err, errContainerLimits)
+	}
+
+	job.Nice = nil
+
+	if err := job.validate(nil); err != nil {
+		t.Errorf("err = %v, want none", err)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	loadAvgPath        = "/proc/loadavg"
+	memoryPressurePath = "/proc/pressure/memory"
+
+	defaultLoadRetry    = time.Minute
+	defaultLoadMaxDelay = time.Hour
+)
+
+var errInvalidPressure = errors.New("invalid pressure stall information")
+
+// hasLoadLimits returns whether job should be deferred while the system is busy.
+func (job *cronJobConfiguration) hasLoadLimits() bool {
+	return job.MaxLoad > 0 || job.MaxMemoryPressure > 0
+}
+
+// loadDeferral returns the interval at which to check the system load again while job is deferred,
+// and the maximum time job is deferred.
+func (job *cronJobConfiguration) loadDeferral() (time.Duration, time.Duration, error) {
+	retry, maxDelay := defaultLoadRetry, defaultLoadMaxDelay
+
+	if job.LoadRetry != "" {
+		var err error
+		if retry, err = time.ParseDuration(job.LoadRetry); err != nil || retry <= 0 {
+			return 0, 0, fmt.Errorf("load retry '%s': %w", job.LoadRetry, errInvalidInterval)
+		}
+	}
+
+	if job.LoadMaxDelay != "" {
+		var err error
+		if maxDelay, err = time.ParseDuration(job.LoadMaxDelay); err != nil || maxDelay < 0 {
+			return 0, 0, fmt.Errorf("load max delay '%s': %w", job.LoadMaxDelay, errInvalidInterval)
+		}
+	}
+
+	return retry, maxDelay, nil
+}
+
+// systemBusy returns why the system is too busy to run job, or an empty string if it is not.
+func systemBusy(job *cronJobConfiguration) (string, error) {
+	if job.MaxLoad > 0 {
+		load, err := readLoadAverage()
+		if err != nil {
+			return "", err
+		}
+
+		if load > job.MaxLoad {
+			return fmt.Sprintf("load average %.2f above %g", load, job.MaxLoad), nil
+		}
+	}
+
+	if job.MaxMemoryPressure > 0 {
+		pressure, err := readMemoryPressure()
+		if err != nil {
+			return "", err
+		}
+
+		if pressure > job.MaxMemoryPressure {
+			return fmt.Sprintf("memory pressure %.2f%% above %g%%", pressure, job.MaxMemoryPressure), nil
+		}
+	}
+
+	return "", nil
+}
+
+// readLoadAverage returns the 1-minute load average.
+func readLoadAverage() (float64, error) {
+	data, err := os.ReadFile(loadAvgPath)
+	if err != nil {
+		return 0, fmt.Errorf("read load average: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("read load average: %w", errInvalidPressure)
+	}
+
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse load average: %w", err)
+	}
+
+	return load, nil
+}
+
+// readMemoryPressure returns the percentage of time in the last 10 seconds in which
+// at least some tasks were stalled on memory.
+func readMemoryPressure() (float64, error) {
+	data, err := os.ReadFile(memoryPressurePath)
+	if err != nil {
+		return 0, fmt.Errorf("read memory pressure: %w", err)
+	}
+
+	return parsePressure(string(data))
+}
+
+// parsePressure returns the "some avg10" value of pressure stall information in the form
+// "some avg10=1.23 avg60=0.50 avg300=0.10 total=12345".
+func parsePressure(data string) (float64, error) {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			value, ok := strings.CutPrefix(field, "avg10=")
+			if !ok {
+				continue
+			}
+
+			pressure, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, fmt.Errorf("parse memory pressure: %w", err)
+			}
+
+			return pressure, nil
+		}
+	}
+
+	return 0, errInvalidPressure
+}
+
+// waitUntilIdle defers a run of job while the system is over one of job's load thresholds, checking again
+// at intervals until the maximum delay has passed. It returns a note describing the deferral, if any,
+// and false if ctx has been canceled while waiting.
+func (r *runner) waitUntilIdle(ctx context.Context, job *cronJobConfiguration) (string, bool) {
+	if !job.hasLoadLimits() {
+		return "", true
+	}
+
+	retry, maxDelay, err := job.loadDeferral()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': %w", job.Name, err).Error())
+		return "", true
+	}
+
+	start := r.clock.Now()
+
+	var firstReason string
+
+	for {
+		reason, err := r.busy(job)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': check system load: %w", job.Name, err).Error())
+		}
+
+		waited := r.clock.Now().Sub(start)
+
+		if reason == "" {
+			if firstReason == "" {
+				return "", true
+			}
+
+			return fmt.Sprintf("deferred %s: %s", waited, firstReason), true
+		}
+
+		if firstReason == "" {
+			firstReason = reason
+
+			r.events.log(logNotice, fmt.Sprintf("job '%s' deferred: %s", job.Name, reason), jobFields(job, nil)...)
+		}
+
+		if waited >= maxDelay {
+			r.events.log(logWarning, fmt.Sprintf("job '%s': %s, running anyway after %s", job.Name, reason, waited),
+				jobFields(job, nil)...)
+			return fmt.Sprintf("deferred %s, maximum delay reached: %s", waited, reason), true
+		}
+
+		timer := r.clock.NewTimer(min(retry, maxDelay-waited))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", false
+
+		case <-timer.C():
+		}
+	}
+}
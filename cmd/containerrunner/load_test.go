@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunner_LoadDeferral(t *testing.T) {
+	clk := newFakeClock()
+
+	var busy atomic.Bool
+
+	busy.Store(true)
+
+	run := newRunner(1024)
+	run.clock = clk
+	run.executor = &fakeExecutor{}
+	run.busy = func(*cronJobConfiguration) (string, error) {
+		if busy.Load() {
+			return "load average 8.00 above 4", nil
+		}
+
+		return "", nil
+	}
+
+	job := cronJobConfiguration{Name: "job", Command: "true", MaxLoad: 4, LoadRetry: "1m", LoadMaxDelay: "5m"}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		run.runScheduled(context.Background(), &job, runInfo{})
+	}()
+
+	clk.waitTimers(t, 1)
+	clk.advance(time.Minute)
+
+	clk.waitTimers(t, 1)
+	busy.Store(false)
+	clk.advance(time.Minute)
+
+	<-done
+
+	history := run.jobHistory("job")
+	if len(history) != 1 {
+		t.Fatalf("history length = %d, want 1", len(history))
+	}
+
+	if want := "deferred 2m0s: load average 8.00 above 4"; history[0].Deferred != want {
+		t.Errorf("deferred = %q, want %q", history[0].Deferred, want)
+	}
+}
+
+func TestRunner_LoadDeferralMaxDelay(t *testing.T) {
+	clk := newFakeClock()
+
+	run := newRunner(1024)
+	run.clock = clk
+	run.executor = &fakeExecutor{}
+	run.busy = func(*cronJobConfiguration) (string, error) {
+		return "memory pressure 50.00% above 10%", nil
+	}
+
+	job := cronJobConfiguration{Name: "job", Command: "true", MaxMemoryPressure: 10, LoadRetry: "2m", LoadMaxDelay: "3m"}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		run.runScheduled(context.Background(), &job, runInfo{})
+	}()
+
+	clk.waitTimers(t, 1)
+	clk.advance(2 * time.Minute)
+
+	clk.waitTimers(t, 1)
+	clk.advance(time.Minute)
+
+	<-done
+
+	history := run.jobHistory("job")
+	if len(history) != 1 || !strings.Contains(history[0].Deferred, "maximum delay reached") {
+		t.Fatalf("history = %+v, want run after maximum delay", history)
+	}
+}
+
+func TestParsePressure(t *testing.T) {
+	pressure, err := parsePressure("some avg10=12.34 avg60=1.00 avg300=0.50 total=123\nfull avg10=1.00 avg60=0.00 avg300=0.00 total=1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pressure != 12.34 {
+		t.Errorf("pressure = %g, want 12.34", pressure)
+	}
+}
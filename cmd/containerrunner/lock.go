@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	lockModeWait = "wait"
+	lockModeSkip = "skip"
+
+	lockPollInterval = 500 * time.Millisecond
+)
+
+var (
+	errLockBusy        = errors.New("lock is held by another job")
+	errInvalidLockName = errors.New("invalid lock name")
+	errInvalidLockMode = errors.New("unknown lock_mode value")
+)
+
+// lockDir returns the directory of job's lock files, which is in its state directory, so that
+// all processes that load the same configuration share the same locks.
+func (job *cronJobConfiguration) lockDir() (string, error) {
+	dir, err := job.jobStateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "locks"), nil
+}
+
+// acquireLocks acquires the named locks of job. Locks are OS file locks, so they are shared
+// with other containerrunner processes that use the same configuration, such as manual runs.
+// Depending on the job's lock mode, it either waits for locks held elsewhere or fails with errLockBusy.
+// The returned function releases all locks.
+func acquireLocks(ctx context.Context, job *cronJobConfiguration) (func(), error) {
+	switch job.LockMode {
+	case "", lockModeWait, lockModeSkip:
+	default:
+		return nil, fmt.Errorf("%s: %w", job.LockMode, errInvalidLockMode)
+	}
+
+	if len(job.Locks) == 0 {
+		return func() {}, nil
+	}
+
+	dir, err := job.lockDir()
+	if err != nil {
+		return nil, err
+	}
+
+	names := append([]string{}, job.Locks...)
+
+	// always lock in the same order to avoid deadlocks between jobs
+	sort.Strings(names)
+
+	files := make([]*os.File, 0, len(names))
+
+	release := func() {
+		for _, file := range files {
+			_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+			_ = file.Close()
+		}
+	}
+
+	for idx, name := range names {
+		if idx > 0 && name == names[idx-1] {
+			continue
+		}
+
+		file, err := acquireLock(ctx, dir, name, job.LockMode == lockModeSkip, job.Name)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("lock '%s': %w", name, err)
+		}
+
+		files = append(files, file)
+	}
+
+	return release, nil
+}
+
+func acquireLock(ctx context.Context, dir string, name string, skip bool, jobName string) (*os.File, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, errInvalidLockName
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for waiting := false; ; waiting = true {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return file, nil
+		}
+
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = file.Close()
+			return nil, fmt.Errorf("flock: %w", err)
+		}
+
+		if skip {
+			_ = file.Close()
+			return nil, errLockBusy
+		}
+
+		if !waiting {
+			fmt.Printf("job '%s': waiting for lock '%s'\n", jobName, name)
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, fmt.Errorf("wait: %w", ctx.Err())
+
+		case <-ticker.C:
+		}
+	}
+}
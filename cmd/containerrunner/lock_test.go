@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireLocks_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, test := range []struct {
+		name string
+		job  cronJobConfiguration
+		want error
+	}{
+		{"unknown mode", cronJobConfiguration{Locks: []string{"db"}, LockMode: "maybe"}, errInvalidLockMode},
+		{"empty name", cronJobConfiguration{Locks: []string{""}}, errInvalidLockName},
+		{"path", cronJobConfiguration{Locks: []string{"a/b"}}, errInvalidLockName},
+		{"parent", cronJobConfiguration{Locks: []string{".."}}, errInvalidLockName},
+	} {
+		test.job.stateDir = dir
+
+		if _, err := acquireLocks(context.Background(), &test.job); !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestAcquireLocks_Contention(t *testing.T) {
+	dir := t.TempDir()
+
+	holder := cronJobConfiguration{Name: "holder", Locks: []string{"db"}, stateDir: dir}
+
+	release, err := acquireLocks(context.Background(), &holder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		locks []string
+		want  error
+	}{
+		{"same lock", []string{"db"}, errLockBusy},
+		{"one of several locks", []string{"cache", "db"}, errLockBusy},
+		{"other lock", []string{"cache"}, nil},
+		{"duplicate lock", []string{"cache", "cache"}, nil},
+	} {
+		job := cronJobConfiguration{Name: "job", Locks: test.locks, LockMode: lockModeSkip, stateDir: dir}
+
+		releaseJob, err := acquireLocks(context.Background(), &job)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+
+		if err == nil {
+			releaseJob()
+		}
+	}
+
+	// locks acquired before a busy one are released again
+	job := cronJobConfiguration{Name: "job", Locks: []string{"cache"}, LockMode: lockModeSkip, stateDir: dir}
+
+	releaseJob, err := acquireLocks(context.Background(), &job)
+	if err != nil {
+		t.Fatalf("lock 'cache' not released: %v", err)
+	}
+
+	releaseJob()
+
+	// locks only depend on the state directory, not on the environment of the process
+	t.Setenv("TMPDIR", t.TempDir())
+
+	job.Locks = []string{"db"}
+
+	if _, err := acquireLocks(context.Background(), &job); !errors.Is(err, errLockBusy) {
+		t.Errorf("err = %v, want %v", err, errLockBusy)
+	}
+
+	other := cronJobConfiguration{Name: "other", Locks: []string{"db"}, LockMode: lockModeSkip, stateDir: t.TempDir()}
+
+	releaseOther, err := acquireLocks(context.Background(), &other)
+	if err != nil {
+		t.Fatalf("lock 'db' of another state directory: %v", err)
+	}
+
+	releaseOther()
+	release()
+
+	// a released lock can be acquired by other jobs
+	releaseJob, err = acquireLocks(context.Background(), &job)
+	if err != nil {
+		t.Fatalf("lock 'db' not released: %v", err)
+	}
+
+	releaseJob()
+}
+
+func TestAcquireLocks_Wait(t *testing.T) {
+	dir := t.TempDir()
+
+	release, err := acquireLocks(context.Background(), &cronJobConfiguration{Name: "holder", Locks: []string{"db"}, stateDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := cronJobConfiguration{Name: "job", Locks: []string{"db"}, LockMode: lockModeWait, stateDir: dir}
+
+	// waiting ends when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := acquireLocks(ctx, &job); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// waiting ends when the lock is released
+	time.AfterFunc(10*time.Millisecond, release)
+
+	releaseJob, err := acquireLocks(context.Background(), &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	releaseJob()
+}
@@ -1,157 +1,374 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
-
-	"github.com/go-co-op/gocron"
 )
 
 var errMissingConfigPath = errors.New("missing configuration file path")
 
+// exit codes of the runner
+const (
+	exitRuntime     = 1
+	exitUsage       = 2
+	exitConfig      = 3
+	exitInterrupted = 130
+)
+
+// exitError is an error that makes the runner exit with a specific code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// exitCodeOf returns the code to exit with because of err, which is exitRuntime unless err says otherwise.
+func exitCodeOf(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	return exitRuntime
+}
+
+var commands = map[string]func(args []string) int{
+	"run":      runCmd,
+	"next":     nextCmd,
+	"logs":     logsCmd,
+	"top":      topCmd,
+	"status":   statusCmd,
+	"validate": validateCmd,
+
+	// internal
+	execLimitedCommand: execLimitedCmd,
+}
+
 func main() {
-	var configPath string
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	os.Exit(runDaemon())
+}
+
+// runDaemon runs the jobs of the configuration given by the command line flags until the runner is stopped,
+// and returns the code to exit with.
+func runDaemon() int {
+	var configPath, configFormat, logTarget, logAddress string
+
+	var strict bool
+
+	controlPath := defaultControlSocket()
+	statusPath := defaultStatusFile()
+	outputBufferSize := "64K"
 
 	flag.StringVar(&configPath, "config", configPath, "path to config file")
+	flag.StringVar(&configFormat, "config-format", configFormat, "config file format (yaml, json or toml), by file extension if empty")
+	flag.StringVar(&controlPath, "control", controlPath, "path to control API socket, empty to disable")
+	flag.StringVar(&statusPath, "status-file", statusPath, "path to status file, empty to disable")
+	flag.StringVar(&logTarget, "log-target", logStdout, "where to log job events (stdout, journald or syslog)")
+	flag.StringVar(&logAddress, "log-address", logAddress, "journald or syslog socket, or host:port for syslog over UDP, by log target if empty")
+	flag.StringVar(&outputBufferSize, "output-buffer", outputBufferSize, "output kept in memory per job and per run")
+	flag.BoolVar(&strict, "strict", strict, "abort startup, and reject reloads, if any job cannot be scheduled")
+
+	flag.Usage = usage
 
 	flag.Parse()
 
 	if configPath == "" {
-		panic(errMissingConfigPath)
+		fmt.Fprintf(os.Stderr, "%s, see %s -h\n", errMissingConfigPath.Error(), os.Args[0])
+		return exitUsage
 	}
 
-	for {
-		done, err := runSignals(context.Background(), configPath)
+	bufferSize, err := parseSize(outputBufferSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("output buffer: %w", err).Error())
+		return exitUsage
+	}
+
+	events, err := newEventLog(logTarget, logAddress)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("log target: %w", err).Error())
+		return exitUsage
+	}
+	defer events.close()
+
+	runner := newRunner(int(bufferSize))
+	runner.statusPath = statusPath
+	runner.events = events
+	runner.strict = strict
+
+	if controlPath != "" {
+		stopControl, err := startControl(controlPath, runner)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, fmt.Errorf("start control API: %w", err).Error())
+		} else {
+			defer stopControl()
 		}
+	}
 
-		if done {
-			break
-		}
+	if err := runSignals(context.Background(), configPath, configFormat, runner); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitCodeOf(err)
 	}
+
+	return 0
 }
 
-func runSignals(ctx context.Context, configPath string) (bool, error) {
-	fmt.Printf("load configuration from file: %s\n", configPath)
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintf(out, "usage: %s -config <path>\n", os.Args[0])
+	fmt.Fprintf(out, "       %s run -config <path> [-dry-run] <job>\n", os.Args[0])
+	fmt.Fprintf(out, "       %s next -config <path> [-n <count>] [-job <job>]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s logs [-control <path>] [-run <id>] [-f] <job>\n", os.Args[0])
+	fmt.Fprintf(out, "       %s top [-control <path>] [-interval <duration>]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s status [-status-file <path>] [-short]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s validate -config <path> [-print]\n\n", os.Args[0])
+
+	flag.PrintDefaults()
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "signals:")
+	fmt.Fprintln(out, "  SIGHUP   reload the configuration, rescheduling only jobs that have changed")
+	fmt.Fprintln(out, "  SIGUSR1  print the status of all jobs to stdout")
+	fmt.Fprintln(out, "  SIGUSR2  run all jobs with on_usr2: true right away")
+	fmt.Fprintln(out, "  SIGTERM  stop, canceling running jobs (also SIGINT)")
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "exit codes:")
+	fmt.Fprintf(out, "  %-3d  stopped by SIGTERM or SIGINT\n", 0)
+	fmt.Fprintf(out, "  %-3d  runtime error, such as a failed init task\n", exitRuntime)
+	fmt.Fprintf(out, "  %-3d  usage error\n", exitUsage)
+	fmt.Fprintf(out, "  %-3d  configuration error, or a job that cannot be scheduled with -strict\n", exitConfig)
+	fmt.Fprintf(out, "  %-3d  interrupted before jobs were scheduled\n", exitInterrupted)
+}
 
-	config, loaded, err := loadConfigDefault(configPath)
+// runSignals runs the configuration at configPath until SIGINT or SIGTERM is received.
+// On SIGHUP, the configuration is reloaded and only the jobs that have changed are rescheduled.
+// Errors carry the code to exit with.
+func runSignals(ctx context.Context, configPath string, configFormat string, runner *runner) error {
+	config, err := loadRunConfig(configPath, configFormat)
 	if err != nil {
-		return true, fmt.Errorf("load configuration: %w", err)
+		return withExitCode(exitConfig, err)
 	}
 
-	if !loaded {
-		fmt.Fprintf(os.Stderr, "%s: file not found, ignoring\n", configPath)
+	if runner.strict {
+		if err := checkSchedules(config.cron(), runner.clock.Now()); err != nil {
+			return withExitCode(exitConfig, fmt.Errorf("schedule: %w", err))
+		}
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	hup := make(chan os.Signal, 1)
-	defer close(hup)
 
 	signal.Notify(hup, syscall.SIGHUP)
 	defer signal.Stop(hup)
 
-	hupDone := make(chan struct{})
-	defer close(hupDone)
+	usr := make(chan os.Signal, 1)
+
+	signal.Notify(usr, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(usr)
+
+	if err := runInit(ctx, config.Init); err != nil {
+		if ctx.Err() != nil {
+			return withExitCode(exitInterrupted, fmt.Errorf("interrupted during init: %w", err))
+		}
+
+		return fmt.Errorf("init: %w", err)
+	}
+
+	// the final pings of runs stopped on shutdown are sent before exiting, if they can be sent in time
+	defer runner.waitHeartbeats(heartbeatShutdownTimeout)
+
+	sched := newScheduler(ctx, runner.clock, runner.runScheduled)
+	defer sched.stop()
+
+	watches := newWatchSet(ctx, runner)
+	defer watches.wait()
+
+	cronConfig := config.cron()
+
+	sched.scheduleAll(cronConfig)
+	watches.setBlackouts(cronConfig.Blackout)
+
+	for _, job := range cronConfig.Jobs {
+		watches.start(job)
+	}
 
-	huped := false
+	runner.setJobs(sched, cronConfig.Jobs)
 
-	go func() {
+	for {
 		select {
-		case <-hupDone:
+		case <-ctx.Done():
+			return nil
 
 		case <-hup:
 			fmt.Println("received SIGHUP")
 
-			huped = true
+			// init tasks only run once, not on reload
+			config, err := loadRunConfig(configPath, configFormat)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, fmt.Errorf("reload: %w", err).Error())
+				continue
+			}
 
-			cancel()
-		}
-	}()
+			newCronConfig := config.cron()
 
-	run(ctx, config)
+			if runner.strict {
+				if err := checkSchedules(newCronConfig, runner.clock.Now()); err != nil {
+					fmt.Fprintln(os.Stderr, fmt.Errorf("reload: schedule: %w, keeping the current configuration", err).Error())
+					continue
+				}
+			}
 
-	if huped {
-		return false, nil
-	}
+			reloadJobs(sched, watches, runner, cronConfig, newCronConfig)
+
+			cronConfig = newCronConfig
 
-	return true, nil
+		case sig := <-usr:
+			if sig == syscall.SIGUSR1 {
+				runner.printStatus(os.Stdout)
+				continue
+			}
+
+			fmt.Println("received SIGUSR2")
+
+			runner.triggerUSR2()
+		}
+	}
 }
 
-func run(ctx context.Context, config *configuration) {
-	cronConfig := &cronConfiguration{}
-	if config.Cron != nil {
-		cronConfig = config.Cron
+func loadRunConfig(configPath string, configFormat string) (*configuration, error) {
+	fmt.Printf("load configuration from file: %s\n", configPath)
+
+	config, loaded, err := loadConfigDefault(configPath, configFormat)
+	if err != nil {
+		return nil, fmt.Errorf("load configuration: %w", err)
 	}
 
-	stop := startCron(cronConfig)
-	defer stop()
+	if !loaded {
+		fmt.Fprintf(os.Stderr, "%s: file not found, ignoring\n", configPath)
+	}
 
-	<-ctx.Done()
+	return config, nil
 }
 
-func startCron(config *cronConfiguration) func() {
-	sched := gocron.NewScheduler(time.Local)
+// what started a job run
+const (
+	triggerSchedule = "schedule"
+	triggerManual   = "manual"
+	triggerWatch    = "watch"
+)
+
+// runInfo describes the circumstances of a job run.
+type runInfo struct {
+	// ID of the run, which is assigned when it starts
+	id string
 
-	now := time.Now()
+	// what started the run, or empty for commands that are not job runs
+	trigger string
 
-	for _, job := range config.Jobs {
-		if err := scheduleJob(job, sched, now); err != nil {
-			fmt.Fprintln(os.Stderr, fmt.Errorf("schedule job '%s': %w", job.Command, err).Error())
-		}
-	}
+	// time the run was due, which is when it was triggered for runs that are not scheduled
+	scheduledAt time.Time
 
-	sched.StartAsync()
+	// why the run has been deferred, if it has
+	deferred string
+}
 
-	return sched.Stop
+// runJob runs job right away as a manual run, prints its output once it has finished, and records the run in the history.
+func (r *runner) runJob(ctx context.Context, job *cronJobConfiguration) {
+	r.runJobWith(ctx, job, runInfo{trigger: triggerManual, scheduledAt: r.clock.Now()})
 }
 
-func scheduleJob(job *cronJobConfiguration, sched *gocron.Scheduler, now time.Time) error {
-	if job.Every != "" {
-		sched.Every(job.Every)
-	} else {
-		sched.Every(1)
-		sched.LimitRunsTo(1)
-	}
+func (r *runner) runJobWith(ctx context.Context, job *cronJobConfiguration, info runInfo) {
+	r.mu.Lock()
+	r.running[job.Name]++
+	r.mu.Unlock()
 
-	if job.Delay != "" {
-		delay, err := time.ParseDuration(job.Delay)
-		if err != nil {
-			return fmt.Errorf("delay '%s': %w", job.Delay, err)
-		}
+	defer func() {
+		r.mu.Lock()
+		r.running[job.Name]--
+		r.mu.Unlock()
 
-		sched.StartAt(now.Add(delay))
-	}
+		r.writeStatus()
+	}()
 
-	if _, err := sched.Do(runCommand, job.Command, job.Args); err != nil {
-		return fmt.Errorf("schedule: %w", err)
-	}
+	record := r.startRun(job, info)
+	info.id = record.ID
 
-	return nil
-}
+	r.events.log(logInfo, fmt.Sprintf("run job '%s'", job.Name), jobFields(job, record)...)
 
-func runCommand(command string, args []string) {
-	fmt.Printf("run command: %s\n", command)
+	ctx = withPIDReporter(ctx, func(pid int) {
+		r.mu.Lock()
+		record.PID = pid
+		r.mu.Unlock()
+	})
 
-	cmd := exec.CommandContext(context.Background(), command, args...)
+	r.writeStatus()
 
-	output, err := cmd.CombinedOutput()
+	beat, err := newHeartbeat(job)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, fmt.Errorf("run command '%s': %w", command, err).Error())
-		return
+		fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': %w", job.Name, err).Error())
+	}
+
+	r.waitHeartbeat(beat)
+	beat.start()
+
+	var output bytes.Buffer
+
+	result, err := runCommand(ctx, r.executor, job, info, io.MultiWriter(&output, r.jobOutput(job.Name), record.output))
+
+	r.finishRun(record, result, err)
+
+	// the run's output buffer bounds the tail sent with the final ping
+	beat.finish(record.output.Bytes(), err)
+
+	// only the scheduled run completes a job that runs at a specific time, so that manual runs,
+	// for example to test it, leave it scheduled
+	if err == nil && job.At != "" && info.trigger == triggerSchedule {
+		if err := job.markAtDone(); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("job '%s': mark as done: %w", job.Name, err).Error())
+		}
 	}
 
-	fmt.Print(string(output))
+	r.events.output(output.Bytes(), jobFields(job, record)...)
+
+	fields := jobFields(job, record, logField{"EXIT_CODE", strconv.Itoa(result.ExitCode)})
+
+	switch {
+	case errors.Is(err, errLockBusy):
+		r.events.log(logNotice, fmt.Sprintf("job '%s' skipped: %s", job.Name, err.Error()), fields...)
+
+	case err != nil:
+		r.events.log(logErr, fmt.Errorf("run job '%s': %w", job.Name, err).Error(), fields...)
+
+	default:
+		r.events.log(logInfo, fmt.Sprintf("job '%s' done", job.Name), fields...)
+	}
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as the exec-limited helper, which commands with limits are started through.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == execLimitedCommand {
+		os.Exit(execLimitedCmd(os.Args[2:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestRunner_RunEnv(t *testing.T) {
+	clk := newFakeClock()
+
+	run := newRunner(1024)
+	run.clock = clk
+	run.executor = processExecutor{}
+
+	job := cronJobConfiguration{
+		Name:    "job",
+		Command: "sh",
+		Args:    []string{"-c", `echo "$CONTAINERRUNNER_JOB $CONTAINERRUNNER_RUN_ID $CONTAINERRUNNER_SCHEDULED_AT $CONTAINERRUNNER_TRIGGER"`},
+	}
+
+	run.setJobs(nil, []*cronJobConfiguration{&job})
+
+	run.runJob(context.Background(), &job)
+
+	history := run.jobHistory("job")
+	if len(history) != 1 {
+		t.Fatalf("history length = %d, want 1", len(history))
+	}
+
+	// runs are timed by the runner's clock
+	if !history[0].Start.Equal(clk.Now()) || !history[0].End.Equal(clk.Now()) {
+		t.Errorf("run from %s to %s, want both at %s", history[0].Start, history[0].End, clk.Now())
+	}
+
+	want := fmt.Sprintf("job %s %s manual\n", history[0].ID, clk.Now().Format(time.RFC3339))
+	if got := string(run.jobOutput("job").Bytes()); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestRunSignals_ExitCodes(t *testing.T) {
+	unschedulable := "cron:\n  jobs:\n    - command: \"true\"\n      every: nope\n"
+
+	for _, test := range []struct {
+		name    string
+		content string
+		strict  bool
+		want    int
+	}{
+		{"invalid", "cron:\n  jobs:\n    - command: \"true\"\n      evry: 1h\n", false, exitConfig},
+		{"strict", unschedulable, true, exitConfig},
+		{"strict watch", "cron:\n  jobs:\n    - command: \"true\"\n      watch:\n        paths: [/nonexistent]\n", true, exitConfig},
+		{"invalid init", "init:\n  - command: \"true\"\n    on_failure: retry\n", false, exitConfig},
+		{"init", "init:\n  - command: \"false\"\n" + unschedulable, false, exitRuntime},
+	} {
+		run := newRunner(1024)
+		run.strict = test.strict
+
+		err := runSignals(context.Background(), writeConfig(t, "config.yaml", test.content), "", run)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+
+		if code := exitCodeOf(err); code != test.want {
+			t.Errorf("%s: exit code = %d, want %d (%v)", test.name, code, test.want, err)
+		}
+	}
+}
+
+func TestSubcommands_ExitCodes(t *testing.T) {
+	valid := writeConfig(t, "config.yaml", "cron:\n  jobs:\n    - name: job\n      command: \"true\"\n")
+	invalid := writeConfig(t, "config.yaml", "cron:\n  jobs:\n    - command: \"true\"\n      evry: 1h\n")
+
+	for _, test := range []struct {
+		name    string
+		command func(args []string) int
+		args    []string
+		want    int
+	}{
+		{"run without job", runCmd, []string{"-config", valid}, exitUsage},
+		{"run unknown job", runCmd, []string{"-config", valid, "other"}, exitUsage},
+		{"run invalid config", runCmd, []string{"-config", invalid, "job"}, exitConfig},
+		{"next without config", nextCmd, nil, exitUsage},
+		{"validate invalid config", validateCmd, []string{"-config", invalid}, exitConfig},
+	} {
+		if code := test.command(test.args); code != test.want {
+			t.Errorf("%s: exit code = %d, want %d", test.name, code, test.want)
+		}
+	}
+}
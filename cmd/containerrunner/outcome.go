@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+var (
+	errOutputMatched     = errors.New("output matches failure pattern")
+	errNoSuccessExitCode = errors.New("not one of the success exit codes")
+)
+
+// outcomeRules decide the outcome of a run from its exit code and output, according to
+// a job's success exit codes and output patterns.
+type outcomeRules struct {
+	successCodes   []int
+	failPattern    *regexp.Regexp
+	succeedPattern *regexp.Regexp
+
+	mu          sync.Mutex
+	lines       lineWriter
+	failLine    *string
+	succeedLine *string
+}
+
+func (job *cronJobConfiguration) hasOutcomeRules() bool {
+	return len(job.SuccessExitCodes) > 0 || job.FailIfOutputMatches != "" || job.SucceedIfOutputMatches != ""
+}
+
+// newOutcomeRules returns the outcome rules of job, or nil if it has none.
+func newOutcomeRules(job *cronJobConfiguration) (*outcomeRules, error) {
+	if !job.hasOutcomeRules() {
+		return nil, nil
+	}
+
+	rules := outcomeRules{
+		successCodes: job.SuccessExitCodes,
+	}
+
+	var err error
+
+	if job.FailIfOutputMatches != "" {
+		if rules.failPattern, err = regexp.Compile(job.FailIfOutputMatches); err != nil {
+			return nil, fmt.Errorf("fail if output matches: %w", err)
+		}
+	}
+
+	if job.SucceedIfOutputMatches != "" {
+		if rules.succeedPattern, err = regexp.Compile(job.SucceedIfOutputMatches); err != nil {
+			return nil, fmt.Errorf("succeed if output matches: %w", err)
+		}
+	}
+
+	rules.lines.line = rules.match
+
+	return &rules, nil
+}
+
+// writer returns a writer that matches output against the rules' patterns before writing it to output.
+func (o *outcomeRules) writer(output io.Writer) io.Writer {
+	if o == nil || (o.failPattern == nil && o.succeedPattern == nil) {
+		return output
+	}
+
+	return io.MultiWriter(output, writerFunc(func(data []byte) (int, error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		return o.lines.Write(data)
+	}))
+}
+
+// match records the first line that matches each pattern.
+func (o *outcomeRules) match(line string) {
+	if o.failLine == nil && o.failPattern != nil && o.failPattern.MatchString(line) {
+		o.failLine = &line
+	}
+
+	if o.succeedLine == nil && o.succeedPattern != nil && o.succeedPattern.MatchString(line) {
+		o.succeedLine = &line
+	}
+}
+
+// decide returns the error of a run with the given result and error, and records the matched line, if any,
+// in the result's details. A matching failure pattern fails the run. Otherwise, a matching success pattern
+// or a success exit code make the run succeed, unless its command could not be run at all. Success exit
+// codes replace the default of 0, so 0 must be listed as well if it still means success.
+func (o *outcomeRules) decide(result *runResult, err error) error {
+	if o == nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.lines.flush()
+	o.mu.Unlock()
+
+	if o.failLine != nil {
+		result.Details["matched_rule"] = "fail_if_output_matches"
+		result.Details["matched_line"] = *o.failLine
+
+		if err == nil {
+			return fmt.Errorf("%w: %s", errOutputMatched, *o.failLine)
+		}
+
+		return err
+	}
+
+	if err != nil && result.ExitCode <= 0 {
+		return err
+	}
+
+	if err == nil && (len(o.successCodes) == 0 || slices.Contains(o.successCodes, 0)) {
+		return nil
+	}
+
+	if o.succeedLine != nil {
+		result.Details["matched_rule"] = "succeed_if_output_matches"
+		result.Details["matched_line"] = *o.succeedLine
+
+		return nil
+	}
+
+	if slices.Contains(o.successCodes, result.ExitCode) {
+		return nil
+	}
+
+	if err == nil {
+		return fmt.Errorf("exit code %d: %w", result.ExitCode, errNoSuccessExitCode)
+	}
+
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunCommand_Outcome(t *testing.T) {
+	errExit := errors.New("run: exit status 1")
+
+	for _, test := range []struct {
+		name        string
+		job         cronJobConfiguration
+		exe         *fakeExecutor
+		wantErr     bool
+		wantMatched string
+	}{
+		{
+			name:    "exit code",
+			job:     cronJobConfiguration{},
+			exe:     &fakeExecutor{code: 1, err: errExit},
+			wantErr: true,
+		},
+		{
+			name: "success exit code",
+			job:  cronJobConfiguration{SuccessExitCodes: []int{1, 2}},
+			exe:  &fakeExecutor{code: 1, err: errExit},
+		},
+		{
+			name:    "success exit codes replace 0",
+			job:     cronJobConfiguration{SuccessExitCodes: []int{1}},
+			exe:     &fakeExecutor{},
+			wantErr: true,
+		},
+		{
+			name: "success exit codes including 0",
+			job:  cronJobConfiguration{SuccessExitCodes: []int{0, 1}},
+			exe:  &fakeExecutor{},
+		},
+		{
+			name:        "fail if output matches",
+			job:         cronJobConfiguration{FailIfOutputMatches: "^ERROR"},
+			exe:         &fakeExecutor{output: "working\nERROR: disk full\ndone\n"},
+			wantErr:     true,
+			wantMatched: "ERROR: disk full",
+		},
+		{
+			name: "fail pattern does not match",
+			job:  cronJobConfiguration{FailIfOutputMatches: "^ERROR"},
+			exe:  &fakeExecutor{output: "no ERROR here\n"},
+		},
+		{
+			name:        "succeed if output matches",
+			job:         cronJobConfiguration{SucceedIfOutputMatches: "nothing to do"},
+			exe:         &fakeExecutor{output: "nothing to do", code: 1, err: errExit},
+			wantMatched: "nothing to do",
+		},
+		{
+			name:        "fail pattern wins",
+			job:         cronJobConfiguration{FailIfOutputMatches: "ERROR", SucceedIfOutputMatches: "OK", SuccessExitCodes: []int{1}},
+			exe:         &fakeExecutor{output: "OK\nERROR\n", code: 1, err: errExit},
+			wantErr:     true,
+			wantMatched: "ERROR",
+		},
+		{
+			name:    "not started",
+			job:     cronJobConfiguration{SucceedIfOutputMatches: ".*"},
+			exe:     &fakeExecutor{code: -1, err: errors.New("start: not found")},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.job.Name = "job"
+			test.job.Command = "cmd"
+
+			var output bytes.Buffer
+
+			result, err := runCommand(context.Background(), test.exe, &test.job, runInfo{}, &output)
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want error: %t", err, test.wantErr)
+			}
+
+			if result.Details["matched_line"] != test.wantMatched {
+				t.Errorf("matched line = %q, want %q", result.Details["matched_line"], test.wantMatched)
+			}
+
+			if output.String() != test.exe.output {
+				t.Errorf("output = %q, want %q", output.String(), test.exe.output)
+			}
+		})
+	}
+}
+
+func TestRunCommand_InvalidOutcomePattern(t *testing.T) {
+	job := cronJobConfiguration{Name: "job", Command: "cmd", FailIfOutputMatches: "("}
+
+	var output bytes.Buffer
+
+	if _, err := runCommand(context.Background(), &fakeExecutor{}, &job, runInfo{}, &output); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+)
+
+// outputBuffer keeps the last bytes written to it and passes all writes on to its followers
+// until it is closed.
+type outputBuffer struct {
+	mu        sync.Mutex
+	size      int
+	data      []byte
+	closed    bool
+	followers map[chan []byte]struct{}
+}
+
+func newOutputBuffer(size int) *outputBuffer {
+	return &outputBuffer{
+		size:      size,
+		followers: map[chan []byte]struct{}{},
+	}
+}
+
+func (b *outputBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, data...)
+	if len(b.data) > b.size {
+		b.data = b.data[len(b.data)-b.size:]
+	}
+
+	if len(b.followers) == 0 {
+		return len(data), nil
+	}
+
+	chunk := append([]byte{}, data...)
+
+	for follower := range b.followers {
+		// drop output for followers that can't keep up rather than blocking the job
+		select {
+		case follower <- chunk:
+		default:
+		}
+	}
+
+	return len(data), nil
+}
+
+// Bytes returns a copy of the buffered output.
+func (b *outputBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]byte{}, b.data...)
+}
+
+// follow returns the buffered output and a channel that receives all subsequent writes,
+// which is closed once the buffer is closed. The returned function stops following.
+func (b *outputBuffer) follow() ([]byte, <-chan []byte, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	follower := make(chan []byte, 256)
+
+	if b.closed {
+		close(follower)
+	} else {
+		b.followers[follower] = struct{}{}
+	}
+
+	return append([]byte{}, b.data...), follower, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.followers, follower)
+	}
+}
+
+// close ends following the buffer once no more output is expected.
+func (b *outputBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+
+	for follower := range b.followers {
+		close(follower)
+		delete(b.followers, follower)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputBuffer_Truncate(t *testing.T) {
+	output := newOutputBuffer(8)
+
+	for _, data := range []string{"abcdef", "ghij", ""} {
+		if _, err := output.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := string(output.Bytes()); got != "cdefghij" {
+		t.Errorf("bytes = %q, want %q", got, "cdefghij")
+	}
+
+	if _, err := output.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(output.Bytes()); got != "23456789" {
+		t.Errorf("bytes = %q, want %q", got, "23456789")
+	}
+}
+
+func TestOutputBuffer_Follow(t *testing.T) {
+	output := newOutputBuffer(4)
+	_, _ = output.Write([]byte("first\n"))
+
+	data, chunks, unfollow := output.follow()
+	defer unfollow()
+
+	if string(data) != "rst\n" {
+		t.Errorf("data = %q, want the truncated buffer", data)
+	}
+
+	// followers get complete writes, not only what fits in the buffer
+	_, _ = output.Write([]byte("second\n"))
+
+	output.close()
+	output.close()
+
+	var followed string
+
+	timeout := time.After(time.Second)
+
+	for done := false; !done; {
+		select {
+		case chunk, ok := <-chunks:
+			followed += string(chunk)
+			done = !ok
+
+		case <-timeout:
+			t.Fatal("following did not end when the buffer was closed")
+		}
+	}
+
+	if followed != "second\n" {
+		t.Errorf("followed = %q, want %q", followed, "second\n")
+	}
+
+	// a closed buffer still keeps its output, and following it ends right away
+	_, _ = output.Write([]byte("late"))
+
+	data, chunks, unfollowClosed := output.follow()
+	defer unfollowClosed()
+
+	if string(data) != "late" {
+		t.Errorf("data = %q, want %q", data, "late")
+	}
+
+	if _, ok := <-chunks; ok {
+		t.Error("following a closed buffer did not end")
+	}
+}
+
+func (b *outputBuffer) hasFollowers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.followers) > 0
+}
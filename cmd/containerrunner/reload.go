@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// jobDiff is the difference between the jobs of two configurations. Jobs are identified by name.
+type jobDiff struct {
+	added     []*cronJobConfiguration
+	removed   []*cronJobConfiguration
+	changed   []*cronJobConfiguration
+	unchanged []*cronJobConfiguration
+
+	blackoutsChanged bool
+}
+
+// diffJobs compares the jobs of the old and new configuration. Changed and unchanged jobs are those of new.
+func diffJobs(oldConfig *cronConfiguration, newConfig *cronConfiguration) jobDiff {
+	diff := jobDiff{
+		blackoutsChanged: !reflect.DeepEqual(oldConfig.Blackout, newConfig.Blackout),
+	}
+
+	oldJobs := make(map[string]*cronJobConfiguration, len(oldConfig.Jobs))
+	for _, job := range oldConfig.Jobs {
+		oldJobs[job.Name] = job
+	}
+
+	newJobs := make(map[string]struct{}, len(newConfig.Jobs))
+
+	for _, job := range newConfig.Jobs {
+		newJobs[job.Name] = struct{}{}
+
+		oldJob, ok := oldJobs[job.Name]
+
+		switch {
+		case !ok:
+			diff.added = append(diff.added, job)
+		case reflect.DeepEqual(oldJob, job):
+			diff.unchanged = append(diff.unchanged, job)
+		default:
+			diff.changed = append(diff.changed, job)
+		}
+	}
+
+	for _, job := range oldConfig.Jobs {
+		if _, ok := newJobs[job.Name]; !ok {
+			diff.removed = append(diff.removed, job)
+		}
+	}
+
+	return diff
+}
+
+func (d jobDiff) String() string {
+	parts := []string{
+		describeJobs("added", d.added),
+		describeJobs("removed", d.removed),
+		describeJobs("changed", d.changed),
+		strconv.Itoa(len(d.unchanged)) + " unchanged",
+	}
+
+	if d.blackoutsChanged {
+		parts = append(parts, "blackout windows changed")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func describeJobs(what string, jobs []*cronJobConfiguration) string {
+	if len(jobs) == 0 {
+		return "0 " + what
+	}
+
+	names := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+
+	return fmt.Sprintf("%d %s (%s)", len(jobs), what, strings.Join(names, ", "))
+}
+
+// reloadJobs applies the jobs of the new configuration. Unchanged jobs keep their schedules and watchers.
+// Removed and changed jobs stop being scheduled, and added and changed jobs are scheduled anew.
+// Runs that have already started are not canceled. If the blackout windows have changed, the schedules
+// of unchanged jobs are restarted with the new windows, but keep their timing.
+func reloadJobs(sched *scheduler, watches *watchSet, runner *runner, oldConfig *cronConfiguration, newConfig *cronConfiguration) {
+	diff := diffJobs(oldConfig, newConfig)
+
+	fmt.Printf("reload: %s\n", diff)
+
+	for _, job := range append(diff.removed, diff.changed...) {
+		sched.unschedule(job.Name)
+		watches.stop(job.Name)
+	}
+
+	if diff.blackoutsChanged {
+		sched.setBlackouts(newConfig.Blackout)
+		watches.setBlackouts(newConfig.Blackout)
+
+		for _, job := range diff.unchanged {
+			if err := sched.reschedule(job); err != nil {
+				fmt.Fprintln(os.Stderr, fmt.Errorf("schedule job '%s': %w", job.Name, err).Error())
+			}
+
+			watches.start(job)
+		}
+	}
+
+	for _, job := range append(diff.added, diff.changed...) {
+		sched.scheduleLogged(job)
+		watches.start(job)
+	}
+
+	runner.setJobs(sched, newConfig.Jobs)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReloadJobs(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	run := newRunner(1024)
+	run.clock = clk
+
+	sched, calls := newTestScheduler(t, clk)
+	watches := newWatchSet(context.Background(), run)
+
+	oldConfig := cronConfiguration{
+		Jobs: []*cronJobConfiguration{
+			{Name: "unchanged", Every: "1h", Delay: "10m"},
+			{Name: "changed", Every: "1h", Delay: "10m"},
+			{Name: "removed", Every: "1h", Delay: "10m"},
+		},
+	}
+
+	sched.scheduleAll(&oldConfig)
+	clk.waitTimers(t, 3)
+
+	clk.advance(30 * time.Minute)
+
+	for range 3 {
+		<-calls
+	}
+
+	clk.waitTimers(t, 3)
+
+	newConfig := cronConfiguration{
+		Jobs: []*cronJobConfiguration{
+			{Name: "unchanged", Every: "1h", Delay: "10m"},
+			{Name: "changed", Every: "2h", Delay: "10m"},
+			{Name: "added", Every: "1h", Delay: "5m"},
+		},
+	}
+
+	diff := diffJobs(&oldConfig, &newConfig)
+	if got, want := diff.String(), "1 added (added), 1 removed (removed), 1 changed (changed), 1 unchanged"; got != want {
+		t.Errorf("diff = %q, want %q", got, want)
+	}
+
+	reloadJobs(sched, watches, run, &oldConfig, &newConfig)
+
+	// the unchanged job keeps its timer, others are scheduled from the time of the reload
+	expectNextRun(t, sched, "unchanged", start.Add(70*time.Minute))
+	expectNextRun(t, sched, "changed", start.Add(40*time.Minute))
+	expectNextRun(t, sched, "added", start.Add(35*time.Minute))
+	expectNextRun(t, sched, "removed", time.Time{})
+
+	clk.waitTimers(t, 3)
+}
+
+func TestReloadJobs_Blackouts(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	run := newRunner(1024)
+	run.clock = clk
+
+	sched, calls := newTestScheduler(t, clk)
+	watches := newWatchSet(context.Background(), run)
+
+	oldConfig := cronConfiguration{
+		Jobs: []*cronJobConfiguration{
+			{Name: "hourly", Every: "1h"},
+			{Name: "once", Command: "true"},
+		},
+	}
+
+	sched.scheduleAll(&oldConfig)
+
+	for range 2 {
+		<-calls
+	}
+
+	clk.waitTimers(t, 1)
+	clk.advance(10 * time.Minute)
+
+	newConfig := cronConfiguration{
+		Jobs: []*cronJobConfiguration{
+			{Name: "hourly", Every: "1h"},
+			{Name: "once", Command: "true"},
+		},
+		Blackout: []*blackoutConfiguration{{Between: "02:00-03:00"}},
+	}
+
+	reloadJobs(sched, watches, run, &oldConfig, &newConfig)
+
+	// unchanged jobs keep their timing, and finished one-shot jobs do not run again
+	expectNoRun(t, calls)
+	expectNextRun(t, sched, "hourly", start.Add(time.Hour))
+	expectNextRun(t, sched, "once", time.Time{})
+
+	clk.waitTimers(t, 1)
+	clk.advance(50 * time.Minute)
+	expectRun(t, calls, "hourly", start.Add(time.Hour))
+}
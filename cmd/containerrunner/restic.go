@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultResticCommand = "restic"
+
+	// exit code of restic if the snapshot was created, but some source files could not be read
+	resticIncompleteCode = 3
+
+	// exit code of restic >= 0.17 if the repository could not be locked
+	resticLockFailedCode = 11
+)
+
+var (
+	errResticLocked     = errors.New("restic repository is locked by another process (run 'restic unlock' if the lock is stale)")
+	errBackupIncomplete = errors.New("incomplete snapshot, some files could not be read")
+	errNoBackupPaths    = errors.New("no paths to back up")
+	errNoBackupRepo     = errors.New("no repository")
+	errBackupAndCommand = errors.New("backup jobs must not have args")
+)
+
+// resticMessage is a line of restic's JSON output.
+type resticMessage struct {
+	MessageType     string `json:"message_type"`
+	FilesNew        int64  `json:"files_new"`
+	FilesChanged    int64  `json:"files_changed"`
+	FilesUnmodified int64  `json:"files_unmodified"`
+	DataAdded       int64  `json:"data_added"`
+	SnapshotID      string `json:"snapshot_id"`
+	During          string `json:"during"`
+	Item            string `json:"item"`
+
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// runBackup backs up the job's paths using restic, then forgets and prunes old snapshots
+// according to the retention policy, and checks the repository if a check is due.
+func runBackup(ctx context.Context, exe executor, job *cronJobConfiguration, output io.Writer, result *runResult) error {
+	backup := job.Backup
+
+	if err := backup.validate(job); err != nil {
+		return err
+	}
+
+	summary := resticMessage{}
+
+	stdout := &lineWriter{
+		line: func(line string) {
+			handleResticLine(line, output, &summary)
+		},
+	}
+
+	code, err := execRestic(ctx, exe, job, backup.backupArgs(), stdout, output)
+	stdout.flush()
+
+	result.ExitCode = code
+
+	// an incomplete backup still saved a snapshot, whose summary is recorded before failing the run
+	if err != nil && code != resticIncompleteCode {
+		return fmt.Errorf("restic backup: %w", err)
+	}
+
+	if summary.MessageType == "summary" {
+		result.Details["snapshot_id"] = summary.SnapshotID
+		result.Details["files_new"] = strconv.FormatInt(summary.FilesNew, 10)
+		result.Details["files_changed"] = strconv.FormatInt(summary.FilesChanged, 10)
+		result.Details["files_unmodified"] = strconv.FormatInt(summary.FilesUnmodified, 10)
+		result.Details["bytes_added"] = strconv.FormatInt(summary.DataAdded, 10)
+
+		fmt.Fprintf(output, "snapshot %s saved: %d new, %d changed, %d unmodified files, %s added\n",
+			summary.SnapshotID, summary.FilesNew, summary.FilesChanged, summary.FilesUnmodified, formatBytes(summary.DataAdded))
+	}
+
+	// old snapshots are not forgotten in favor of an incomplete one
+	if err != nil {
+		result.Details["incomplete"] = "true"
+
+		return fmt.Errorf("restic backup: %w", errBackupIncomplete)
+	}
+
+	if forgetArgs := backup.forgetArgs(); forgetArgs != nil {
+		if result.ExitCode, err = execRestic(ctx, exe, job, forgetArgs, output, output); err != nil {
+			return fmt.Errorf("restic forget: %w", err)
+		}
+	}
+
+	return runBackupCheck(ctx, exe, job, output, result)
+}
+
+func runBackupCheck(ctx context.Context, exe executor, job *cronJobConfiguration, output io.Writer, result *runResult) error {
+	backup := job.Backup
+
+	if backup.CheckEvery == "" {
+		return nil
+	}
+
+	every, err := time.ParseDuration(backup.CheckEvery)
+	if err != nil {
+		return fmt.Errorf("check every '%s': %w", backup.CheckEvery, err)
+	}
+
+	stamp, err := job.checkStampPath()
+	if err != nil {
+		return fmt.Errorf("check stamp: %w", err)
+	}
+
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < every {
+		return nil
+	}
+
+	if result.ExitCode, err = execRestic(ctx, exe, job, backup.resticArgs("check"), output, output); err != nil {
+		return fmt.Errorf("restic check: %w", err)
+	}
+
+	result.Details["checked"] = "true"
+
+	if err := os.MkdirAll(filepath.Dir(stamp), 0o700); err != nil {
+		return fmt.Errorf("create check stamp directory: %w", err)
+	}
+
+	if err := os.WriteFile(stamp, []byte(time.Now().Format(time.RFC3339)+"\n"), 0o600); err != nil {
+		return fmt.Errorf("write check stamp: %w", err)
+	}
+
+	return nil
+}
+
+// execRestic runs restic with args and reports repository lock failures as errResticLocked.
+func execRestic(ctx context.Context, exe executor, job *cronJobConfiguration, args []string,
+	stdout io.Writer, stderr io.Writer,
+) (int, error) {
+	var errOutput bytes.Buffer
+
+	code, err := exe.execute(ctx, job, job.resticCommand(), args, stdout, io.MultiWriter(stderr, &errOutput))
+	if err != nil && (code == resticLockFailedCode || strings.Contains(errOutput.String(), "repository is already locked")) {
+		return code, errResticLocked
+	}
+
+	return code, err
+}
+
+func handleResticLine(line string, output io.Writer, summary *resticMessage) {
+	msg := resticMessage{}
+
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		fmt.Fprintln(output, line)
+		return
+	}
+
+	switch msg.MessageType {
+	case "summary":
+		*summary = msg
+
+	case "error":
+		if msg.Error != nil {
+			fmt.Fprintf(output, "error during %s: %s: %s\n", msg.During, msg.Item, msg.Error.Message)
+		}
+
+	case "status", "verbose_status":
+
+	default:
+		fmt.Fprintln(output, line)
+	}
+}
+
+func (job *cronJobConfiguration) resticCommand() string {
+	if job.Command != "" {
+		return job.Command
+	}
+
+	return defaultResticCommand
+}
+
+func (b *backupConfiguration) validate(job *cronJobConfiguration) error {
+	if len(job.Args) > 0 {
+		return errBackupAndCommand
+	}
+
+	if b.Repository == "" {
+		return errNoBackupRepo
+	}
+
+	if len(b.Paths) == 0 {
+		return errNoBackupPaths
+	}
+
+	return nil
+}
+
+func (b *backupConfiguration) resticArgs(args ...string) []string {
+	global := []string{"--repo", expandHome(b.Repository)}
+
+	if b.PasswordFile != "" {
+		global = append(global, "--password-file", expandHome(b.PasswordFile))
+	}
+
+	return append(global, args...)
+}
+
+func (b *backupConfiguration) backupArgs() []string {
+	args := []string{"backup", "--json"}
+
+	for _, exclude := range b.Excludes {
+		args = append(args, "--exclude", exclude)
+	}
+
+	for _, path := range b.Paths {
+		args = append(args, expandHome(path))
+	}
+
+	return b.resticArgs(args...)
+}
+
+// forgetArgs returns the arguments to forget and prune snapshots, or nil if there is no retention policy.
+func (b *backupConfiguration) forgetArgs() []string {
+	if b.KeepDaily <= 0 && b.KeepWeekly <= 0 && b.KeepMonthly <= 0 {
+		return nil
+	}
+
+	args := []string{"forget", "--prune"}
+
+	if b.KeepDaily > 0 {
+		args = append(args, "--keep-daily", strconv.Itoa(b.KeepDaily))
+	}
+
+	if b.KeepWeekly > 0 {
+		args = append(args, "--keep-weekly", strconv.Itoa(b.KeepWeekly))
+	}
+
+	if b.KeepMonthly > 0 {
+		args = append(args, "--keep-monthly", strconv.Itoa(b.KeepMonthly))
+	}
+
+	return b.resticArgs(args...)
+}
+
+// checkStampPath returns the path of the file in job's state directory whose modification time records the
+// last check of its repository.
+func (job *cronJobConfiguration) checkStampPath() (string, error) {
+	dir, err := job.jobStateDir()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(expandHome(job.Backup.Repository)))
+
+	return filepath.Join(dir, "restic-check-"+hex.EncodeToString(hash[:8])), nil
+}
+
+// lineWriter calls line for every complete line written to it.
+type lineWriter struct {
+	line func(line string)
+	buf  []byte
+}
+
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+
+		w.line(string(w.buf[:idx]))
+		w.buf = w.buf[idx+1:]
+	}
+
+	return len(data), nil
+}
+
+// flush calls line for any remaining incomplete line.
+func (w *lineWriter) flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+
+	w.line(string(w.buf))
+	w.buf = nil
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
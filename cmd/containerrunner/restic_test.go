@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resticExecutor is a stand-in for restic that writes fixed output and exits with a fixed code.
+type resticExecutor struct {
+	stdout string
+	stderr string
+	code   int
+	err    error
+}
+
+func (e resticExecutor) execute(_ context.Context, _ *cronJobConfiguration, _ string, _ []string,
+	stdout io.Writer, stderr io.Writer,
+) (int, error) {
+	_, _ = io.WriteString(stdout, e.stdout)
+	_, _ = io.WriteString(stderr, e.stderr)
+
+	return e.code, e.err
+}
+
+func TestHandleResticLine(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		line        string
+		wantOutput  string
+		wantSummary string
+	}{
+		{
+			name:        "summary",
+			line:        `{"message_type":"summary","files_new":2,"files_changed":1,"files_unmodified":7,"data_added":1024,"snapshot_id":"abc123"}`,
+			wantSummary: "abc123",
+		},
+		{
+			name:       "error",
+			line:       `{"message_type":"error","error":{"message":"permission denied"},"during":"archival","item":"/data/secret"}`,
+			wantOutput: "error during archival: /data/secret: permission denied\n",
+		},
+		{
+			name: "status",
+			line: `{"message_type":"status","percent_done":0.5}`,
+		},
+		{
+			name:       "unknown message",
+			line:       `{"message_type":"verbose_update"}`,
+			wantOutput: `{"message_type":"verbose_update"}` + "\n",
+		},
+		{
+			name:       "plain text",
+			line:       "using parent snapshot abc123",
+			wantOutput: "using parent snapshot abc123\n",
+		},
+	} {
+		var output strings.Builder
+
+		summary := resticMessage{}
+
+		handleResticLine(test.line, &output, &summary)
+
+		if got := output.String(); got != test.wantOutput {
+			t.Errorf("%s: output = %q, want %q", test.name, got, test.wantOutput)
+		}
+
+		if summary.SnapshotID != test.wantSummary {
+			t.Errorf("%s: summary snapshot = %q, want %q", test.name, summary.SnapshotID, test.wantSummary)
+		}
+	}
+}
+
+func TestExecRestic_Locked(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	for _, test := range []struct {
+		name string
+		exe  resticExecutor
+		want error
+	}{
+		{"lock failed exit code", resticExecutor{code: resticLockFailedCode, err: exitErr}, errResticLocked},
+		{
+			"lock message",
+			resticExecutor{stderr: "unable to create lock in backend: repository is already locked by PID 42\n", code: 1, err: exitErr},
+			errResticLocked,
+		},
+		{"other failure", resticExecutor{stderr: "Fatal: wrong password\n", code: 1, err: exitErr}, exitErr},
+		{"success", resticExecutor{stderr: "repository is already locked, retrying\n"}, nil},
+	} {
+		job := cronJobConfiguration{Name: "backup", Backup: &backupConfiguration{Repository: "/repo", Paths: []string{"/data"}}}
+
+		var stderr strings.Builder
+
+		_, err := execRestic(context.Background(), test.exe, &job, nil, io.Discard, &stderr)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: err = %v, want %v", test.name, err, test.want)
+		}
+
+		// restic's own error output is passed on either way
+		if stderr.String() != test.exe.stderr {
+			t.Errorf("%s: stderr = %q, want %q", test.name, stderr.String(), test.exe.stderr)
+		}
+	}
+}
+
+func TestRunBackup_Summary(t *testing.T) {
+	exe := fakeExecutor{
+		output: `{"message_type":"status","percent_done":1}` + "\n" +
+			`{"message_type":"summary","files_new":2,"files_changed":1,"files_unmodified":7,"data_added":2048,"snapshot_id":"abc123"}` + "\n",
+	}
+
+	job := cronJobConfiguration{
+		Name:   "backup",
+		Backup: &backupConfiguration{Repository: "/repo", Paths: []string{"/data"}, KeepDaily: 7},
+	}
+
+	var output strings.Builder
+
+	result := runResult{Details: map[string]string{}}
+
+	if err := runBackup(context.Background(), &exe, &job, &output, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"snapshot_id":      "abc123",
+		"files_new":        "2",
+		"files_changed":    "1",
+		"files_unmodified": "7",
+		"bytes_added":      "2048",
+	}
+
+	for key, value := range want {
+		if result.Details[key] != value {
+			t.Errorf("details[%s] = %q, want %q", key, result.Details[key], value)
+		}
+	}
+
+	if !strings.HasPrefix(output.String(), "snapshot abc123 saved: 2 new, 1 changed, 7 unmodified files") {
+		t.Errorf("output = %q, want the backup summary", output.String())
+	}
+
+	if len(exe.commands) != 2 || exe.commands[0][3] != "backup" || exe.commands[1][3] != "forget" {
+		t.Errorf("commands = %v, want backup and forget", exe.commands)
+	}
+}
+
+func TestRunBackup_Incomplete(t *testing.T) {
+	exe := fakeExecutor{
+		output: `{"message_type":"error","error":{"message":"permission denied"},"during":"archival","item":"/data/secret"}` + "\n" +
+			`{"message_type":"summary","files_new":1,"data_added":512,"snapshot_id":"def456"}` + "\n",
+		code: resticIncompleteCode,
+		err:  errors.New("exit status 3"),
+	}
+
+	job := cronJobConfiguration{
+		Name:   "backup",
+		Backup: &backupConfiguration{Repository: "/repo", Paths: []string{"/data"}, KeepDaily: 7},
+	}
+
+	var output strings.Builder
+
+	result := runResult{Details: map[string]string{}}
+
+	if err := runBackup(context.Background(), &exe, &job, &output, &result); !errors.Is(err, errBackupIncomplete) {
+		t.Errorf("err = %v, want %v", err, errBackupIncomplete)
+	}
+
+	if result.ExitCode != resticIncompleteCode {
+		t.Errorf("exit code = %d, want %d", result.ExitCode, resticIncompleteCode)
+	}
+
+	if result.Details["snapshot_id"] != "def456" || result.Details["incomplete"] != "true" {
+		t.Errorf("details = %v, want the summary of the incomplete snapshot", result.Details)
+	}
+
+	if !strings.Contains(output.String(), "snapshot def456 saved: 1 new") {
+		t.Errorf("output = %q, want the backup summary", output.String())
+	}
+
+	// snapshots are not forgotten after an incomplete backup
+	if len(exe.commands) != 1 {
+		t.Errorf("commands = %v, want only backup", exe.commands)
+	}
+}
+
+func TestRunBackupCheck_Stamp(t *testing.T) {
+	dir := t.TempDir()
+
+	job := cronJobConfiguration{
+		Name:     "backup",
+		Backup:   &backupConfiguration{Repository: "/repo", Paths: []string{"/data"}, CheckEvery: "24h"},
+		stateDir: dir,
+	}
+
+	exe := fakeExecutor{}
+
+	for range 2 {
+		result := runResult{Details: map[string]string{}}
+
+		if err := runBackupCheck(context.Background(), &exe, &job, io.Discard, &result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the second run is within the check interval
+	if len(exe.commands) != 1 || exe.commands[0][len(exe.commands[0])-1] != "check" {
+		t.Errorf("commands = %v, want a single check", exe.commands)
+	}
+
+	stamp, err := job.checkStampPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Dir(stamp) != dir {
+		t.Errorf("check stamp = %s, want it in the state directory %s", stamp, dir)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var errNotScheduled = errors.New("no jobs are scheduled")
+
+// runner holds the state of a running instance that outlives configuration reloads.
+type runner struct {
+	clock            clock
+	executor         executor
+	outputBufferSize int
+
+	// busy returns why the system is too busy to run a job
+	busy func(job *cronJobConfiguration) (string, error)
+
+	// where job events are logged
+	events *eventLog
+
+	// whether jobs that cannot be scheduled abort startup and reject reloads instead of being skipped
+	strict bool
+
+	// heartbeats of finished runs that may still be sending pings
+	heartbeats sync.WaitGroup
+
+	// path of the status file, or empty to not write one
+	statusPath string
+	statusMu   sync.Mutex
+
+	mu       sync.Mutex
+	sched    *scheduler
+	jobs     []*cronJobConfiguration
+	history  map[string][]*runRecord
+	outputs  map[string]*outputBuffer
+	paused   map[string]bool
+	running  map[string]int
+	deferred map[string]bool
+
+	// number of consecutive failed runs per job
+	failures map[string]int
+}
+
+func newRunner(outputBufferSize int) *runner {
+	return &runner{
+		clock:            realClock{},
+		executor:         newJobExecutor(),
+		outputBufferSize: outputBufferSize,
+		busy:             systemBusy,
+		events:           &eventLog{target: logStdout},
+		history:          map[string][]*runRecord{},
+		outputs:          map[string]*outputBuffer{},
+		paused:           map[string]bool{},
+		running:          map[string]int{},
+		deferred:         map[string]bool{},
+		failures:         map[string]int{},
+	}
+}
+
+// jobInfo describes a configured job and its schedule.
+type jobInfo struct {
+	Name     string     `json:"name"`
+	Command  string     `json:"command"`
+	Schedule string     `json:"schedule"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	Paused   bool       `json:"paused,omitempty"`
+	Running  int        `json:"running,omitempty"`
+	LastRun  *runRecord `json:"last_run,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// setJobs replaces the current jobs with jobs that have been scheduled by sched.
+func (r *runner) setJobs(sched *scheduler, jobs []*cronJobConfiguration) {
+	r.mu.Lock()
+	r.sched = sched
+	r.jobs = jobs
+	r.mu.Unlock()
+
+	r.writeStatus()
+}
+
+// hasJob returns whether a job with the given name is configured.
+func (r *runner) hasJob(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.findJob(name)
+
+	return ok
+}
+
+func (r *runner) findJob(name string) (*cronJobConfiguration, bool) {
+	for _, job := range r.jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+
+	return nil, false
+}
+
+// runScheduled runs job unless it is paused, deferring it while the system is busy.
+// It is used for all automatic runs.
+func (r *runner) runScheduled(ctx context.Context, job *cronJobConfiguration, info runInfo) {
+	r.mu.Lock()
+	paused := r.paused[job.Name]
+	deferred := r.deferred[job.Name]
+
+	if !paused && !deferred {
+		r.deferred[job.Name] = true
+	}
+	r.mu.Unlock()
+
+	switch {
+	case paused:
+		r.events.log(logNotice, fmt.Sprintf("job '%s' skipped: paused", job.Name), jobFields(job, nil)...)
+		return
+
+	case deferred:
+		r.events.log(logNotice, fmt.Sprintf("job '%s' skipped: previous run still deferred", job.Name), jobFields(job, nil)...)
+		return
+	}
+
+	note, ok := r.waitUntilIdle(ctx, job)
+
+	r.mu.Lock()
+	delete(r.deferred, job.Name)
+	r.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	info.deferred = note
+
+	r.runJobWith(ctx, job, info)
+}
+
+// trigger runs the named job right away, even if it is paused.
+func (r *runner) trigger(name string) error {
+	r.mu.Lock()
+	job, ok := r.findJob(name)
+	sched := r.sched
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%s: %w", name, errUnknownJob)
+	}
+
+	if sched == nil {
+		return errNotScheduled
+	}
+
+	sched.trigger(job, r.runJob)
+
+	return nil
+}
+
+// triggerUSR2 runs all jobs that are marked to run on SIGUSR2 right away.
+func (r *runner) triggerUSR2() {
+	r.mu.Lock()
+	jobs := slices.Clone(r.jobs)
+	r.mu.Unlock()
+
+	count := 0
+
+	for _, job := range jobs {
+		if !job.OnUSR2 {
+			continue
+		}
+
+		if err := r.trigger(job.Name); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("trigger job '%s': %w", job.Name, err).Error())
+			continue
+		}
+
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("no jobs to run on SIGUSR2")
+	}
+}
+
+// setPaused pauses or resumes automatic runs of the named job.
+func (r *runner) setPaused(name string, paused bool) error {
+	r.mu.Lock()
+
+	if _, ok := r.findJob(name); !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("%s: %w", name, errUnknownJob)
+	}
+
+	if paused {
+		r.paused[name] = true
+	} else {
+		delete(r.paused, name)
+	}
+
+	r.mu.Unlock()
+
+	r.writeStatus()
+
+	return nil
+}
+
+// jobOutput returns the output buffer of the named job, which contains the output of its recent runs.
+func (r *runner) jobOutput(name string) *outputBuffer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	output, ok := r.outputs[name]
+	if !ok {
+		output = newOutputBuffer(r.outputBufferSize)
+		r.outputs[name] = output
+	}
+
+	return output
+}
+
+func (r *runner) jobInfos() []jobInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]jobInfo, 0, len(r.jobs))
+
+	for _, job := range r.jobs {
+		info := jobInfo{
+			Name:     job.Name,
+			Command:  job.Command,
+			Schedule: job.describeSchedule(),
+			Paused:   r.paused[job.Name],
+			Running:  r.running[job.Name],
+		}
+
+		if history := r.history[job.Name]; len(history) > 0 {
+			lastRun := *history[len(history)-1]
+			info.LastRun = &lastRun
+		}
+
+		if _, _, err := nextAllowedRun(job, nil, time.Time{}, time.Time{}); err != nil {
+			info.Error = err.Error()
+		}
+
+		if r.sched != nil {
+			if next, ok := r.sched.nextRun(job.Name); ok {
+				info.NextRun = &next
+			}
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errInvalidInterval = errors.New("interval must be positive")
+
+// nextRunTimes returns up to count times at or after after at which job is scheduled to run,
+// given that the scheduler was started at start. Jobs that are only triggered by file changes
+// have no run times. Jobs that run at a specific time run at start if that time has passed.
+func nextRunTimes(job *cronJobConfiguration, start time.Time, after time.Time, count int) ([]time.Time, error) {
+	if job.At != "" {
+		return nextAtTimes(job, start, after, count)
+	}
+
+	if job.Every == "" && job.Watch != nil {
+		return nil, nil
+	}
+
+	first := start
+
+	if job.Delay != "" {
+		delay, err := time.ParseDuration(job.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("delay '%s': %w", job.Delay, err)
+		}
+
+		first = start.Add(delay)
+	}
+
+	if job.Every == "" {
+		if first.Before(after) || count < 1 {
+			return nil, nil
+		}
+
+		return []time.Time{first}, nil
+	}
+
+	every, err := time.ParseDuration(job.Every)
+	if err != nil {
+		return nil, fmt.Errorf("every '%s': %w", job.Every, err)
+	}
+
+	if every <= 0 {
+		return nil, fmt.Errorf("every '%s': %w", job.Every, errInvalidInterval)
+	}
+
+	next := first
+	if next.Before(after) {
+		next = first.Add((after.Sub(first) + every - 1) / every * every)
+	}
+
+	times := make([]time.Time, 0, count)
+	for ; len(times) < count; next = next.Add(every) {
+		times = append(times, next)
+	}
+
+	return times, nil
+}
+
+func nextAtTimes(job *cronJobConfiguration, start time.Time, after time.Time, count int) ([]time.Time, error) {
+	if job.Every != "" || job.Delay != "" {
+		return nil, errAtAndEvery
+	}
+
+	at, err := parseAt(job.At)
+	if err != nil {
+		return nil, err
+	}
+
+	if at.Before(start) {
+		at = start
+	}
+
+	if at.Before(after) || count < 1 {
+		return nil, nil
+	}
+
+	return []time.Time{at}, nil
+}
+
+func (job *cronJobConfiguration) describeSchedule() string {
+	var desc string
+
+	switch {
+	case job.At != "":
+		desc = "at " + job.At
+	case job.Every == "" && job.Watch != nil:
+		desc = "on file changes"
+	case job.Every == "":
+		desc = "once"
+	default:
+		desc = "every " + job.Every
+	}
+
+	if job.Delay != "" {
+		desc += ", after " + job.Delay
+	}
+
+	if (job.Every != "" || job.At != "") && job.Watch != nil {
+		desc += ", and on file changes"
+	}
+
+	if window := job.describeWindow(); window != "" {
+		desc += ", only " + window
+	}
+
+	return desc
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// scheduler runs jobs at the times given by their schedules.
+type scheduler struct {
+	clock clock
+	run   func(ctx context.Context, job *cronJobConfiguration, info runInfo)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	next map[string]time.Time
+	jobs map[string]*scheduledJob
+
+	// blackout windows that apply to all jobs
+	blackouts []*blackoutConfiguration
+}
+
+// scheduledJob is a job whose schedule is running.
+type scheduledJob struct {
+	job *cronJobConfiguration
+
+	// time the schedule is relative to
+	start time.Time
+
+	// time from which the loop looks for the next run, guarded by the scheduler's mutex
+	after time.Time
+
+	blackouts []*blackoutConfiguration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newScheduler creates a scheduler that calls run for every job run. The scheduler stops when ctx
+// is canceled or stop is called.
+func newScheduler(ctx context.Context, clock clock, run func(ctx context.Context, job *cronJobConfiguration, info runInfo),
+) *scheduler {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &scheduler{
+		clock:  clock,
+		run:    run,
+		ctx:    ctx,
+		cancel: cancel,
+		next:   map[string]time.Time{},
+		jobs:   map[string]*scheduledJob{},
+	}
+}
+
+// schedule starts scheduling job relative to the current time. Jobs that are only triggered by file changes are ignored.
+func (s *scheduler) schedule(job *cronJobConfiguration) error {
+	now := s.clock.Now()
+
+	return s.scheduleFrom(job, now, now)
+}
+
+// reschedule restarts the schedule of job with the current blackout windows, if it is scheduled.
+// The schedule keeps the time it is relative to, and continues with the first run that has not
+// started yet, or that is due from now on if runs have been missed.
+func (s *scheduler) reschedule(job *cronJobConfiguration) error {
+	s.mu.Lock()
+	scheduled, ok := s.jobs[job.Name]
+
+	var start, after time.Time
+
+	if ok {
+		start, after = scheduled.start, scheduled.after
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if now := s.clock.Now(); now.After(after) {
+		after = now
+	}
+
+	return s.scheduleFrom(job, start, after)
+}
+
+// scheduleFrom starts scheduling job relative to start with the first run at or after after,
+// replacing any schedule of a job with the same name.
+func (s *scheduler) scheduleFrom(job *cronJobConfiguration, start time.Time, after time.Time) error {
+	s.unschedule(job.Name)
+
+	s.mu.Lock()
+	blackouts := s.blackouts
+	s.mu.Unlock()
+
+	// validate the schedule before starting
+	run, ok, err := nextAllowedRun(job, blackouts, start, after)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	scheduled := scheduledJob{
+		job:       job,
+		start:     start,
+		after:     after,
+		blackouts: blackouts,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.jobs[job.Name] = &scheduled
+	s.mu.Unlock()
+
+	s.setNext(job.Name, run.at)
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		defer close(scheduled.done)
+
+		s.loop(ctx, &scheduled)
+	}()
+
+	return nil
+}
+
+// unschedule stops scheduling the named job. Runs that have already started are not canceled.
+func (s *scheduler) unschedule(name string) {
+	s.mu.Lock()
+	scheduled, ok := s.jobs[name]
+	delete(s.jobs, name)
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	scheduled.cancel()
+	<-scheduled.done
+}
+
+// setBlackouts sets the blackout windows for jobs that are scheduled from now on.
+func (s *scheduler) setBlackouts(blackouts []*blackoutConfiguration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blackouts = blackouts
+}
+
+func (s *scheduler) loop(ctx context.Context, scheduled *scheduledJob) {
+	job := scheduled.job
+
+	defer s.setNext(job.Name, time.Time{})
+
+	// a schedule without further runs is done, so it must not be restarted by reschedule
+	defer func() {
+		s.mu.Lock()
+		if s.jobs[job.Name] == scheduled {
+			delete(s.jobs, job.Name)
+		}
+		s.mu.Unlock()
+	}()
+
+	s.mu.Lock()
+	after := scheduled.after
+	s.mu.Unlock()
+
+	for {
+		run, ok, err := nextAllowedRun(job, scheduled.blackouts, scheduled.start, after)
+		if err != nil || !ok {
+			return
+		}
+
+		next := run.at
+
+		s.setNext(job.Name, next)
+
+		timer := s.clock.NewTimer(next.Sub(s.clock.Now()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+
+		case <-timer.C():
+		}
+
+		if run.reason != "" {
+			fmt.Printf("job '%s' %s\n", job.Name, run.reason)
+		}
+
+		// runs use the scheduler's context, so that they are not canceled when the job is unscheduled
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+
+			s.run(s.ctx, job, runInfo{trigger: triggerSchedule, scheduledAt: next})
+		}()
+
+		// skip runs that have been missed, for example because the system was suspended
+		after = next.Add(time.Nanosecond)
+		if now := s.clock.Now(); now.After(after) {
+			after = now
+		}
+
+		s.mu.Lock()
+		scheduled.after = after
+		s.mu.Unlock()
+	}
+}
+
+func (s *scheduler) setNext(name string, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if next.IsZero() {
+		delete(s.next, name)
+		return
+	}
+
+	s.next[name] = next
+}
+
+// nextRun returns the time of the next run of the named job, if any.
+func (s *scheduler) nextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, ok := s.next[name]
+
+	return next, ok
+}
+
+// trigger calls run for job right away, outside of its schedule.
+func (s *scheduler) trigger(job *cronJobConfiguration, run func(ctx context.Context, job *cronJobConfiguration)) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		run(s.ctx, job)
+	}()
+}
+
+// stop stops scheduling, cancels all running jobs and waits for them to finish.
+func (s *scheduler) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// checkSchedules returns the errors of all jobs of config that cannot be scheduled at now,
+// or whose watch cannot be started.
+func checkSchedules(config *cronConfiguration, now time.Time) error {
+	var errs []error
+
+	for _, job := range config.Jobs {
+		if _, _, err := nextAllowedRun(job, config.Blackout, now, now); err != nil {
+			errs = append(errs, fmt.Errorf("job '%s': %w", job.Name, err))
+		}
+
+		if job.Watch != nil {
+			if err := job.Watch.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("job '%s': watch: %w", job.Name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// scheduleAll schedules all jobs of config, printing errors and each job's first run.
+func (s *scheduler) scheduleAll(config *cronConfiguration) {
+	s.setBlackouts(config.Blackout)
+
+	for _, job := range config.Jobs {
+		s.scheduleLogged(job)
+	}
+}
+
+// scheduleLogged schedules job, printing an error or its first run.
+func (s *scheduler) scheduleLogged(job *cronJobConfiguration) {
+	if err := s.schedule(job); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("schedule job '%s': %w", job.Name, err).Error())
+		return
+	}
+
+	if next, ok := s.nextRun(job.Name); ok {
+		fmt.Printf("job '%s': %s, next run at %s\n", job.Name, job.describeSchedule(), next.Format(time.DateTime))
+		return
+	}
+
+	fmt.Printf("job '%s': %s\n", job.Name, job.describeSchedule())
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only changes when advanced by a test.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// runCall records a call of a scheduler's run function.
+type runCall struct {
+	job  string
+	time time.Time
+	ctx  context.Context
+}
+
+// fakeExecutor records executed commands instead of running them.
+type fakeExecutor struct {
+	mu       sync.Mutex
+	commands [][]string
+	output   string
+	code     int
+	err      error
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		ch:       make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		timer.ch <- c.now
+		return &timer
+	}
+
+	c.timers = append(c.timers, &timer)
+
+	return &timer
+}
+
+// advance moves the clock forward by d and fires all timers that are due.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+
+		timer.ch <- c.now
+	}
+
+	c.timers = pending
+}
+
+func (c *fakeClock) pendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// waitTimers waits until count timers are pending.
+func (c *fakeClock) waitTimers(t *testing.T, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for c.pendingTimers() != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending timers = %d, want %d", c.pendingTimers(), count)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitTimerAt waits until a timer is pending that fires at deadline.
+func (c *fakeClock) waitTimerAt(t *testing.T, deadline time.Time) {
+	t.Helper()
+
+	timeout := time.Now().Add(5 * time.Second)
+
+	for {
+		c.mu.Lock()
+
+		for _, timer := range c.timers {
+			if timer.deadline.Equal(deadline) {
+				c.mu.Unlock()
+				return
+			}
+		}
+
+		c.mu.Unlock()
+
+		if time.Now().After(timeout) {
+			t.Fatalf("no timer pending at %s", deadline)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for idx, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:idx], t.clock.timers[idx+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *fakeExecutor) execute(_ context.Context, _ *cronJobConfiguration, command string, args []string,
+	stdout io.Writer, _ io.Writer,
+) (int, error) {
+	e.mu.Lock()
+	e.commands = append(e.commands, append([]string{command}, args...))
+	e.mu.Unlock()
+
+	_, _ = io.WriteString(stdout, e.output)
+
+	return e.code, e.err
+}
+
+func newTestScheduler(t *testing.T, clk clock) (*scheduler, <-chan runCall) {
+	t.Helper()
+
+	calls := make(chan runCall, 10)
+
+	sched := newScheduler(context.Background(), clk, func(ctx context.Context, job *cronJobConfiguration, _ runInfo) {
+		calls <- runCall{
+			job:  job.Name,
+			time: clk.Now(),
+			ctx:  ctx,
+		}
+	})
+
+	t.Cleanup(sched.stop)
+
+	return sched, calls
+}
+
+func expectRun(t *testing.T, calls <-chan runCall, job string, want time.Time) runCall {
+	t.Helper()
+
+	select {
+	case call := <-calls:
+		if call.job != job || !call.time.Equal(want) {
+			t.Fatalf("run %s at %s, want %s at %s", call.job, call.time, job, want)
+		}
+
+		return call
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job %s did not run at %s", job, want)
+	}
+
+	return runCall{}
+}
+
+func expectNoRun(t *testing.T, calls <-chan runCall) {
+	t.Helper()
+
+	select {
+	case call := <-calls:
+		t.Fatalf("unexpected run of %s at %s", call.job, call.time)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectNextRun(t *testing.T, sched *scheduler, job string, want time.Time) {
+	t.Helper()
+
+	next, ok := sched.nextRun(job)
+
+	switch {
+	case want.IsZero() && ok:
+		t.Fatalf("next run of %s = %s, want none", job, next)
+	case !want.IsZero() && !next.Equal(want):
+		t.Fatalf("next run of %s = %s, want %s", job, next, want)
+	}
+}
+
+func TestScheduler_Interval(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&cronJobConfiguration{Name: "job", Every: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectRun(t, calls, "job", start)
+
+	clk.waitTimers(t, 1)
+	expectNextRun(t, sched, "job", start.Add(time.Hour))
+
+	clk.advance(30 * time.Minute)
+	expectNoRun(t, calls)
+
+	clk.advance(30 * time.Minute)
+	expectRun(t, calls, "job", start.Add(time.Hour))
+
+	clk.waitTimers(t, 1)
+	expectNextRun(t, sched, "job", start.Add(2*time.Hour))
+
+	clk.advance(time.Hour)
+	expectRun(t, calls, "job", start.Add(2*time.Hour))
+}
+
+func TestScheduler_IntervalSkipsMissedRuns(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&cronJobConfiguration{Name: "job", Every: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectRun(t, calls, "job", start)
+
+	clk.waitTimers(t, 1)
+	clk.advance(3*time.Hour + 30*time.Minute)
+	expectRun(t, calls, "job", start.Add(3*time.Hour+30*time.Minute))
+
+	clk.waitTimers(t, 1)
+	expectNoRun(t, calls)
+	expectNextRun(t, sched, "job", start.Add(4*time.Hour))
+}
+
+func TestScheduler_Delay(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&cronJobConfiguration{Name: "job", Every: "1h", Delay: "10m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.waitTimers(t, 1)
+	expectNoRun(t, calls)
+	expectNextRun(t, sched, "job", start.Add(10*time.Minute))
+
+	clk.advance(10 * time.Minute)
+	expectRun(t, calls, "job", start.Add(10*time.Minute))
+
+	clk.waitTimers(t, 1)
+	expectNextRun(t, sched, "job", start.Add(70*time.Minute))
+
+	clk.advance(time.Hour)
+	expectRun(t, calls, "job", start.Add(70*time.Minute))
+}
+
+func TestScheduler_OneShot(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&cronJobConfiguration{Name: "job"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectRun(t, calls, "job", start)
+
+	clk.waitTimers(t, 0)
+	clk.advance(24 * time.Hour)
+	expectNoRun(t, calls)
+	expectNextRun(t, sched, "job", time.Time{})
+}
+
+func TestScheduler_OneShotDelayed(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&cronJobConfiguration{Name: "job", Delay: "5m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.waitTimers(t, 1)
+	expectNoRun(t, calls)
+
+	clk.advance(5 * time.Minute)
+	expectRun(t, calls, "job", start.Add(5*time.Minute))
+
+	clk.waitTimers(t, 0)
+	clk.advance(time.Hour)
+	expectNoRun(t, calls)
+}
+
+func TestScheduler_WatchOnly(t *testing.T) {
+	clk := newFakeClock()
+
+	sched, calls := newTestScheduler(t, clk)
+
+	job := cronJobConfiguration{
+		Name:  "job",
+		Watch: &watchConfiguration{Paths: []string{"."}},
+	}
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.waitTimers(t, 0)
+	expectNoRun(t, calls)
+	expectNextRun(t, sched, "job", time.Time{})
+}
+
+func TestScheduler_InvalidSchedule(t *testing.T) {
+	sched, _ := newTestScheduler(t, newFakeClock())
+
+	for _, job := range []*cronJobConfiguration{
+		{Name: "every", Every: "soon"},
+		{Name: "delay", Delay: "later"},
+		{Name: "negative", Every: "-1h"},
+	} {
+		if err := sched.schedule(job); err == nil {
+			t.Errorf("schedule %s: expected error", job.Name)
+		}
+	}
+}
+
+func TestScheduler_Reload(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	job := cronJobConfiguration{Name: "job", Every: "1h"}
+
+	sched, calls := newTestScheduler(t, clk)
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	call := expectRun(t, calls, "job", start)
+
+	clk.waitTimers(t, 1)
+	clk.advance(30 * time.Minute)
+
+	// stopping the scheduler on shutdown cancels running jobs and pending runs
+	sched.stop()
+
+	if call.ctx.Err() == nil {
+		t.Fatal("context of running job not canceled")
+	}
+
+	clk.waitTimers(t, 0)
+
+	// a new scheduler starts all schedules from the time it is started
+	sched, calls = newTestScheduler(t, clk)
+
+	if err := sched.schedule(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	expectRun(t, calls, "job", start.Add(30*time.Minute))
+
+	clk.waitTimers(t, 1)
+	expectNextRun(t, sched, "job", start.Add(90*time.Minute))
+
+	clk.advance(30 * time.Minute)
+	expectNoRun(t, calls)
+
+	clk.advance(30 * time.Minute)
+	expectRun(t, calls, "job", start.Add(90*time.Minute))
+}
+
+func TestRunner_RunJob(t *testing.T) {
+	exe := fakeExecutor{
+		output: "hello\n",
+		code:   3,
+	}
+
+	run := newRunner(1024)
+	run.executor = &exe
+
+	job := cronJobConfiguration{
+		Name:    "job",
+		Command: "echo",
+		Args:    []string{"hello"},
+	}
+
+	run.setJobs(nil, []*cronJobConfiguration{&job})
+
+	run.runJob(context.Background(), &job)
+
+	if len(exe.commands) != 1 || len(exe.commands[0]) != 2 || exe.commands[0][0] != "echo" || exe.commands[0][1] != "hello" {
+		t.Fatalf("commands = %v, want [[echo hello]]", exe.commands)
+	}
+
+	history := run.jobHistory("job")
+	if len(history) != 1 {
+		t.Fatalf("history length = %d, want 1", len(history))
+	}
+
+	if history[0].ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", history[0].ExitCode)
+	}
+
+	if got := string(run.jobOutput("job").Bytes()); got != "hello\n" {
+		t.Errorf("output = %q, want %q", got, "hello\n")
+	}
+}
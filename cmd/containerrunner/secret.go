@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	secretPrefix = "secret://"
+	secretMask   = "***"
+)
+
+var (
+	errInvalidSecretRef    = errors.New("invalid secret reference")
+	errUnknownSecretSource = errors.New("unknown secret source")
+	errSecretKeyNotFound   = errors.New("key not found")
+)
+
+// resolveSecrets returns a copy of job whose environment has all secret references resolved,
+// and a masker for the resolved values. Secret references have the form
+// "secret://<source>/<path>[#<key>]", where source is one of:
+//
+//   - file: the contents of the file at path
+//   - gpg: the contents of the file at path, decrypted using gpg and the local agent
+//   - dotenv: the value of key in the .env file at path
+//
+// Paths are absolute, or relative to the home directory if they start with "~".
+func resolveSecrets(ctx context.Context, job *cronJobConfiguration) (*cronJobConfiguration, *masker, error) {
+	mask := &masker{}
+
+	if !job.hasSecrets() {
+		return job, mask, nil
+	}
+
+	resolved := *job
+	resolved.Env = make(map[string]string, len(job.Env))
+
+	for key, value := range job.Env {
+		if !strings.HasPrefix(value, secretPrefix) {
+			resolved.Env[key] = value
+			continue
+		}
+
+		secret, err := resolveSecret(ctx, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("env %s: %w", key, err)
+		}
+
+		resolved.Env[key] = secret
+
+		mask.add(secret)
+	}
+
+	return &resolved, mask, nil
+}
+
+func (job *cronJobConfiguration) hasSecrets() bool {
+	for _, value := range job.Env {
+		if strings.HasPrefix(value, secretPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func resolveSecret(ctx context.Context, ref string) (string, error) {
+	source, path, ok := strings.Cut(strings.TrimPrefix(ref, secretPrefix), "/")
+	if !ok || path == "" {
+		return "", fmt.Errorf("%s: %w", ref, errInvalidSecretRef)
+	}
+
+	path, key, _ := strings.Cut(path, "#")
+
+	if strings.HasPrefix(path, "~") {
+		path = expandHome(path)
+	} else {
+		path = "/" + path
+	}
+
+	switch source {
+	case "file":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read secret file: %w", err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case "gpg":
+		return decryptGPG(ctx, path)
+
+	case "dotenv":
+		return readDotEnv(path, key)
+
+	default:
+		return "", fmt.Errorf("%s: %w", source, errUnknownSecretSource)
+	}
+}
+
+func decryptGPG(ctx context.Context, path string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "gpg", "--batch", "--quiet", "--decrypt", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gpg decrypt %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+func readDotEnv(path string, key string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open .env file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		return value, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read .env file: %w", err)
+	}
+
+	return "", fmt.Errorf("%s: %w", key, errSecretKeyNotFound)
+}
+
+// masker replaces secret values in text.
+type masker struct {
+	secrets []string
+}
+
+func (m *masker) add(secret string) {
+	if secret == "" {
+		return
+	}
+
+	m.secrets = append(m.secrets, secret)
+
+	// output is masked line by line, so the lines of a multi-line secret are secrets of their own
+	if strings.Contains(secret, "\n") {
+		for _, line := range strings.Split(secret, "\n") {
+			m.add(strings.TrimSpace(line))
+		}
+	}
+}
+
+// mask replaces every run of text that is covered by secrets with a single mask,
+// so that secrets which overlap or contain each other are masked as a whole.
+func (m *masker) mask(text string) string {
+	masked := make([]bool, len(text))
+	found := false
+
+	for _, secret := range m.secrets {
+		for start := 0; start < len(text); {
+			idx := strings.Index(text[start:], secret)
+			if idx < 0 {
+				break
+			}
+
+			idx += start
+
+			for pos := idx; pos < idx+len(secret); pos++ {
+				masked[pos] = true
+			}
+
+			found = true
+			start = idx + 1
+		}
+	}
+
+	if !found {
+		return text
+	}
+
+	var result strings.Builder
+
+	for pos := range len(text) {
+		switch {
+		case !masked[pos]:
+			result.WriteByte(text[pos])
+		case pos == 0 || !masked[pos-1]:
+			result.WriteString(secretMask)
+		}
+	}
+
+	return result.String()
+}
+
+// maskError returns err with secret values masked in its message.
+func (m *masker) maskError(err error) error {
+	if err == nil || len(m.secrets) == 0 {
+		return err
+	}
+
+	return &maskedError{
+		err: err,
+		msg: m.mask(err.Error()),
+	}
+}
+
+func (m *masker) maskDetails(details map[string]string) {
+	for key, value := range details {
+		details[key] = m.mask(value)
+	}
+}
+
+// writer returns a writer that masks secret values line by line before writing them to output.
+// The returned function writes any remaining incomplete line.
+func (m *masker) writer(output io.Writer) (io.Writer, func()) {
+	if len(m.secrets) == 0 {
+		return output, func() {}
+	}
+
+	var mu sync.Mutex
+
+	lines := &lineWriter{
+		line: func(line string) {
+			_, _ = io.WriteString(output, m.mask(line)+"\n")
+		},
+	}
+
+	writer := writerFunc(func(data []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return lines.Write(data)
+	})
+
+	return writer, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(lines.buf) > 0 {
+			_, _ = io.WriteString(output, m.mask(string(lines.buf)))
+			lines.buf = nil
+		}
+	}
+}
+
+type maskedError struct {
+	err error
+	msg string
+}
+
+func (e *maskedError) Error() string {
+	return e.msg
+}
+
+func (e *maskedError) Unwrap() error {
+	return e.err
+}
+
+type writerFunc func(data []byte) (int, error)
+
+func (f writerFunc) Write(data []byte) (int, error) {
+	return f(data)
+}
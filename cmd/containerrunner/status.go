@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// status is the content of the status file.
+type status struct {
+	Updated time.Time            `json:"updated"`
+	PID     int                  `json:"pid"`
+	Running []string             `json:"running"`
+	Failing int                  `json:"failing"`
+	Jobs    map[string]jobStatus `json:"jobs"`
+}
+
+// jobStatus is the status of a single job in the status file.
+type jobStatus struct {
+	LastResult string     `json:"last_result,omitempty"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	ExitCode   int        `json:"exit_code,omitempty"`
+	Failures   int        `json:"failures,omitempty"`
+	Running    int        `json:"running,omitempty"`
+	Paused     bool       `json:"paused,omitempty"`
+}
+
+func defaultStatusFile() string {
+	return filepath.Join(os.TempDir(), "containerrunner-status.json")
+}
+
+// writeStatus writes the current state of all jobs to the status file, if there is one.
+func (r *runner) writeStatus() {
+	if r.statusPath == "" {
+		return
+	}
+
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	data, err := json.MarshalIndent(r.status(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("encode status: %w", err).Error())
+		return
+	}
+
+	if err := writeFileAtomic(r.statusPath, append(data, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("write status file: %w", err).Error())
+	}
+}
+
+func (r *runner) status() status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stat := status{
+		Updated: time.Now(),
+		PID:     os.Getpid(),
+		Running: []string{},
+		Jobs:    make(map[string]jobStatus, len(r.jobs)),
+	}
+
+	for _, job := range r.jobs {
+		jobStat := jobStatus{
+			Failures: r.failures[job.Name],
+			Running:  r.running[job.Name],
+			Paused:   r.paused[job.Name],
+		}
+
+		for idx := len(r.history[job.Name]) - 1; idx >= 0; idx-- {
+			record := r.history[job.Name][idx]
+			if record.End.IsZero() {
+				continue
+			}
+
+			jobStat.LastRun = &record.End
+			jobStat.ExitCode = record.ExitCode
+			jobStat.LastResult = "ok"
+
+			switch {
+			case record.Skipped != "":
+				jobStat.LastResult = "skipped"
+			case record.Error != "":
+				jobStat.LastResult = "failed"
+			}
+
+			break
+		}
+
+		if jobStat.Running > 0 {
+			stat.Running = append(stat.Running, job.Name)
+		}
+
+		if jobStat.Failures > 0 {
+			stat.Failing++
+		}
+
+		stat.Jobs[job.Name] = jobStat
+	}
+
+	return stat
+}
+
+// writeFileAtomic replaces the file at path with data, so that readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("write temporary file: %w", err)
+	}
+
+	if err := file.Chmod(0o644); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("chmod temporary file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("rename temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// statusCmd implements the "status" subcommand, which prints the status of all jobs from the status file.
+func statusCmd(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+
+	statusPath := defaultStatusFile()
+
+	var short bool
+
+	flags.StringVar(&statusPath, "status-file", statusPath, "path to status file of the running instance")
+	flags.BoolVar(&short, "short", short, "print a compact token for shell prompts, empty if all is well")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s status [-status-file <path>] [-short]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	data, err := os.ReadFile(statusPath)
+	if err != nil {
+		if short && errors.Is(err, os.ErrNotExist) {
+			return 0
+		}
+
+		fmt.Fprintln(os.Stderr, fmt.Errorf("read status file: %w", err).Error())
+
+		return exitRuntime
+	}
+
+	stat := status{}
+
+	if err := json.Unmarshal(data, &stat); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("decode status file: %w", err).Error())
+		return exitRuntime
+	}
+
+	if short {
+		fmt.Print(stat.short())
+		return 0
+	}
+
+	stat.print()
+
+	return 0
+}
+
+// short returns a compact summary of stat: "!n" for n failing jobs, "*n" for n running jobs,
+// or "stopped" if the instance that wrote stat is no longer running.
+func (s *status) short() string {
+	if !processAlive(s.PID) {
+		return "stopped"
+	}
+
+	tokens := []string{}
+
+	if s.Failing > 0 {
+		tokens = append(tokens, "!"+strconv.Itoa(s.Failing))
+	}
+
+	if len(s.Running) > 0 {
+		tokens = append(tokens, "*"+strconv.Itoa(len(s.Running)))
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+func (s *status) print() {
+	if !processAlive(s.PID) {
+		fmt.Printf("containerrunner (pid %d) is not running, last update at %s\n\n", s.PID, s.Updated.Format(time.DateTime))
+	}
+
+	names := make([]string, 0, len(s.Jobs))
+	for name := range s.Jobs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	tab := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tab, "JOB\tLAST RESULT\tLAST RUN\tFAILURES\tSTATE")
+
+	for _, name := range names {
+		job := s.Jobs[name]
+
+		lastResult, lastRun := "-", "-"
+
+		if job.LastRun != nil {
+			lastResult = job.LastResult
+			lastRun = job.LastRun.Format(time.DateTime)
+		}
+
+		if job.LastResult == "failed" && job.ExitCode > 0 {
+			lastResult = fmt.Sprintf("failed (exit %d)", job.ExitCode)
+		}
+
+		fmt.Fprintf(tab, "%s\t%s\t%s\t%d\t%s\n", name, lastResult, lastRun, job.Failures,
+			jobState(jobInfo{Running: job.Running, Paused: job.Paused}))
+	}
+
+	_ = tab.Flush()
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// printStatus prints the status of all jobs, including their running and last runs, to out.
+func (r *runner) printStatus(out io.Writer) {
+	now := time.Now()
+
+	fmt.Fprintf(out, "status at %s\n", now.Format(time.DateTime))
+
+	for _, info := range r.jobInfos() {
+		fmt.Fprintf(out, "job '%s': %s", info.Name, info.Schedule)
+
+		if info.NextRun != nil {
+			fmt.Fprintf(out, ", next run at %s", info.NextRun.Format(time.DateTime))
+		}
+
+		if info.Paused {
+			fmt.Fprint(out, ", paused")
+		}
+
+		fmt.Fprintln(out)
+
+		if info.Error != "" {
+			fmt.Fprintf(out, "  error: %s\n", info.Error)
+		}
+
+		var lastRun *runRecord
+
+		for _, record := range r.jobHistory(info.Name) {
+			if !record.End.IsZero() {
+				lastRun = &record
+				continue
+			}
+
+			fmt.Fprintf(out, "  running: run %s since %s", record.ID, record.Start.Format(time.DateTime))
+
+			if record.PID > 0 {
+				fmt.Fprintf(out, ", pid %d", record.PID)
+			}
+
+			fmt.Fprintln(out)
+		}
+
+		if lastRun == nil {
+			continue
+		}
+
+		result := "ok"
+
+		switch {
+		case lastRun.Skipped != "":
+			result = "skipped: " + lastRun.Skipped
+		case lastRun.Error != "":
+			result = "failed: " + lastRun.Error
+		}
+
+		fmt.Fprintf(out, "  last run: run %s at %s, exit code %d, %s\n", lastRun.ID, lastRun.End.Format(time.DateTime),
+			lastRun.ExitCode, result)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunner_WriteStatus(t *testing.T) {
+	exe := fakeExecutor{code: 1, err: errors.New("run: exit status 1")}
+
+	run := newRunner(1024)
+	run.executor = &exe
+	run.statusPath = filepath.Join(t.TempDir(), "status.json")
+
+	failing := cronJobConfiguration{Name: "failing", Command: "false"}
+	idle := cronJobConfiguration{Name: "idle", Command: "true"}
+
+	run.setJobs(nil, []*cronJobConfiguration{&failing, &idle})
+
+	run.runJob(context.Background(), &failing)
+	run.runJob(context.Background(), &failing)
+
+	data, err := os.ReadFile(run.statusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat := status{}
+
+	if err := json.Unmarshal(data, &stat); err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Failing != 1 || len(stat.Running) != 0 {
+		t.Errorf("failing = %d, running = %v, want 1, []", stat.Failing, stat.Running)
+	}
+
+	job := stat.Jobs["failing"]
+	if job.LastResult != "failed" || job.ExitCode != 1 || job.Failures != 2 {
+		t.Errorf("job status = %+v, want failed with exit code 1 and 2 failures", job)
+	}
+
+	if got := stat.short(); got != "!1" {
+		t.Errorf("short = %q, want %q", got, "!1")
+	}
+
+	// a successful run resets the failure count
+	exe.code, exe.err = 0, nil
+
+	run.runJob(context.Background(), &failing)
+
+	if got := run.status(); got.Failing != 0 || got.Jobs["failing"].LastResult != "ok" {
+		t.Errorf("status = %+v, want no failing jobs", got)
+	}
+}
+
+func TestRunner_WriteStatusSkipped(t *testing.T) {
+	dir := t.TempDir()
+
+	exe := fakeExecutor{code: 1, err: errors.New("run: exit status 1")}
+
+	run := newRunner(1024)
+	run.executor = &exe
+	run.statusPath = filepath.Join(t.TempDir(), "status.json")
+
+	job := cronJobConfiguration{Name: "job", Command: "false", Locks: []string{"db"}, LockMode: lockModeSkip, stateDir: dir}
+
+	run.setJobs(nil, []*cronJobConfiguration{&job})
+
+	run.runJob(context.Background(), &job)
+
+	// the job is skipped while another job holds its lock
+	release, err := acquireLocks(context.Background(), &cronJobConfiguration{Name: "other", Locks: []string{"db"}, stateDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer release()
+
+	run.runJob(context.Background(), &job)
+
+	if len(exe.commands) != 1 {
+		t.Fatalf("commands = %v, want 1 command", exe.commands)
+	}
+
+	stat := run.status()
+
+	if got := stat.Jobs["job"]; got.LastResult != "skipped" || got.Failures != 1 || stat.Failing != 1 {
+		t.Errorf("status = %+v, want skipped with the failure of the previous run", stat)
+	}
+
+	history := run.jobHistory("job")
+	if last := history[len(history)-1]; last.Error != "" || !strings.Contains(last.Skipped, errLockBusy.Error()) {
+		t.Errorf("run record = %+v, want skipped for a busy lock", last)
+	}
+}
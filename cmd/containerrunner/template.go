@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	errUnknownTemplate  = errors.New("unknown template")
+	errTemplateCycle    = errors.New("templates extend each other")
+	errTemplateName     = errors.New("must not have a name")
+	errDefaultsExtends  = errors.New("must not extend a template")
+	errEmptyTemplateKey = errors.New("template without a name")
+)
+
+// resolveJobs applies the defaults and the templates of c to its jobs, so that every job
+// has all of its settings and extends no template anymore.
+//
+// A job is based on the defaults, then on the chain of templates it extends, and then on
+// its own settings, each overriding the former according to these rules:
+//   - maps, such as env, are merged key by key
+//   - lists, such as args or locks, are replaced as a whole; an empty list clears the list
+//   - nested settings, such as limits or backup, are merged field by field
+//   - any other setting is replaced if it is set, that is not empty, zero or false
+func (c *configuration) resolveJobs() error {
+	if c.Defaults != nil {
+		if c.Defaults.Name != "" {
+			return fmt.Errorf("defaults: %w", errTemplateName)
+		}
+
+		if c.Defaults.Extends != "" {
+			return fmt.Errorf("defaults: %w", errDefaultsExtends)
+		}
+	}
+
+	for name, template := range c.Templates {
+		if name == "" {
+			return errEmptyTemplateKey
+		}
+
+		if template != nil && template.Name != "" {
+			return fmt.Errorf("template '%s': %w", name, errTemplateName)
+		}
+
+		if _, err := c.template(name, nil); err != nil {
+			return err
+		}
+	}
+
+	if c.Cron == nil {
+		return nil
+	}
+
+	base := c.Defaults
+	if base == nil {
+		base = &cronJobConfiguration{}
+	}
+
+	for idx, job := range c.Cron.Jobs {
+		resolved := base
+
+		if job.Extends != "" {
+			template, err := c.template(job.Extends, nil)
+			if err != nil {
+				if job.Name != "" {
+					return fmt.Errorf("job '%s': %w", job.Name, err)
+				}
+
+				return fmt.Errorf("job %d: %w", idx+1, err)
+			}
+
+			resolved = mergeJob(resolved, template)
+		}
+
+		resolved = mergeJob(resolved, job)
+		resolved.Extends = ""
+
+		c.Cron.Jobs[idx] = resolved
+	}
+
+	return nil
+}
+
+// template returns the named template with the templates it extends applied, but not the defaults.
+// seen holds the names of the templates that extend it.
+func (c *configuration) template(name string, seen []string) (*cronJobConfiguration, error) {
+	for _, other := range seen {
+		if other == name {
+			return nil, fmt.Errorf("%s: %w", strings.Join(append(seen, name), " -> "), errTemplateCycle)
+		}
+	}
+
+	template, ok := c.Templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, errUnknownTemplate)
+	}
+
+	if template == nil {
+		template = &cronJobConfiguration{}
+	}
+
+	if template.Extends == "" {
+		return template, nil
+	}
+
+	parent, err := c.template(template.Extends, append(seen, name))
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := mergeJob(parent, template)
+	resolved.Extends = ""
+
+	return resolved, nil
+}
+
+// mergeJob returns a new job with the settings of job applied on top of those of base.
+func mergeJob(base *cronJobConfiguration, job *cronJobConfiguration) *cronJobConfiguration {
+	merged := mergeValue(reflect.ValueOf(*base), reflect.ValueOf(*job)).Interface().(cronJobConfiguration)
+
+	return &merged
+}
+
+func mergeValue(base reflect.Value, over reflect.Value) reflect.Value {
+	switch base.Kind() {
+	case reflect.Struct:
+		merged := reflect.New(base.Type()).Elem()
+
+		for idx := range base.NumField() {
+			// unexported fields are not part of the configuration file
+			if !base.Type().Field(idx).IsExported() {
+				continue
+			}
+
+			merged.Field(idx).Set(mergeValue(base.Field(idx), over.Field(idx)))
+		}
+
+		return merged
+
+	case reflect.Map:
+		if base.IsNil() && over.IsNil() {
+			return base
+		}
+
+		merged := reflect.MakeMap(base.Type())
+
+		for _, value := range []reflect.Value{base, over} {
+			iter := value.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+
+		return merged
+
+	case reflect.Pointer:
+		if base.IsNil() || base.Elem().Kind() != reflect.Struct {
+			if over.IsNil() {
+				return base
+			}
+
+			return over
+		}
+
+		overElem := reflect.Zero(base.Type().Elem())
+		if !over.IsNil() {
+			overElem = over.Elem()
+		}
+
+		merged := reflect.New(base.Type().Elem())
+		merged.Elem().Set(mergeValue(base.Elem(), overElem))
+
+		return merged
+
+	case reflect.Slice:
+		if over.IsNil() {
+			return base
+		}
+
+		return over
+
+	default:
+		if over.IsZero() {
+			return base
+		}
+
+		return over
+	}
+}
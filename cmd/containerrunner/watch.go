@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	defaultWatchDebounce = time.Second
+
+	// how often a watched path that has been removed is checked for being recreated
+	watchRetryInterval = time.Second
+
+	watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+		syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+)
+
+var errNoWatchPaths = errors.New("no paths to watch")
+
+// watcher watches a job's paths using inotify and reports changed paths.
+// A watched path that is removed or moved away is watched again once it is recreated.
+type watcher struct {
+	config *watchConfiguration
+	clock  clock
+	fd     int
+	file   *os.File
+
+	mu    sync.Mutex
+	paths map[int32]string
+	roots map[int32]string
+
+	// watched paths that have been removed
+	missing map[string]struct{}
+}
+
+// watchSet runs the watchers of jobs that have a watch trigger. Changes to watched paths
+// run the job through the same pipeline as scheduled runs. Runs use the watch set's context,
+// so that they are not canceled when a watcher is stopped on reload.
+type watchSet struct {
+	ctx    context.Context
+	runner *runner
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	watches   map[string]*runningWatch
+	blackouts []*blackoutConfiguration
+}
+
+type runningWatch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newWatchSet creates a watch set whose watchers stop when ctx is canceled.
+func newWatchSet(ctx context.Context, runner *runner) *watchSet {
+	return &watchSet{
+		ctx:     ctx,
+		runner:  runner,
+		watches: map[string]*runningWatch{},
+	}
+}
+
+// setBlackouts sets the blackout windows for watchers that are started from now on.
+func (w *watchSet) setBlackouts(blackouts []*blackoutConfiguration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.blackouts = blackouts
+}
+
+// start starts watching job's paths if job has a watch trigger, replacing any watcher of a job with the same name.
+func (w *watchSet) start(job *cronJobConfiguration) {
+	w.stop(job.Name)
+
+	if job.Watch == nil {
+		return
+	}
+
+	watch, err := newWatcher(job.Watch, w.runner.clock)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("watch job '%s': %w", job.Name, err).Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+
+	running := runningWatch{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	w.watches[job.Name] = &running
+	blackouts := w.blackouts
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+		defer close(running.done)
+
+		if err := watch.run(ctx, job, blackouts, w.run); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("watch job '%s': %w", job.Name, err).Error())
+		}
+	}()
+}
+
+// run runs job in the background because of a change to its watched paths.
+func (w *watchSet) run(job *cronJobConfiguration) {
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+
+		w.runner.runScheduled(w.ctx, job, runInfo{trigger: triggerWatch, scheduledAt: w.runner.clock.Now()})
+	}()
+}
+
+// stop stops the watcher of the named job, if any, and waits for it to finish.
+func (w *watchSet) stop(name string) {
+	w.mu.Lock()
+	running, ok := w.watches[name]
+	delete(w.watches, name)
+	w.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	running.cancel()
+	<-running.done
+}
+
+// wait waits for all watchers and the runs they started to finish after the watch set's context has been canceled.
+func (w *watchSet) wait() {
+	w.wg.Wait()
+}
+
+func newWatcher(config *watchConfiguration, clk clock) (*watcher, error) {
+	if len(config.Paths) == 0 {
+		return nil, errNoWatchPaths
+	}
+
+	if _, err := config.debounce(); err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+
+	watch := watcher{
+		config:  config,
+		clock:   clk,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		paths:   map[int32]string{},
+		roots:   map[int32]string{},
+		missing: map[string]struct{}{},
+	}
+
+	for _, path := range config.Paths {
+		path = expandHome(path)
+
+		if err := watch.add(path, path); err != nil {
+			_ = watch.file.Close()
+			return nil, err
+		}
+	}
+
+	return &watch, nil
+}
+
+// run watches for changes until ctx is canceled, and calls run for job once changes have settled
+// and job may run according to its activity window and the blackout windows.
+func (w *watcher) run(ctx context.Context, job *cronJobConfiguration, blackouts []*blackoutConfiguration,
+	run func(job *cronJobConfiguration),
+) error {
+	changes := make(chan string)
+
+	readErr := make(chan error, 1)
+
+	go func() {
+		readErr <- w.read(ctx, changes)
+	}()
+
+	err := w.handleChanges(ctx, job, blackouts, changes, readErr, run)
+
+	_ = w.file.Close()
+
+	if err == nil {
+		<-readErr
+	}
+
+	return err
+}
+
+// handleChanges debounces changes until ctx is canceled or errs receives an error, and calls run for job
+// once changes have settled. Removed paths are checked for being recreated while there are any.
+func (w *watcher) handleChanges(ctx context.Context, job *cronJobConfiguration, blackouts []*blackoutConfiguration,
+	changes <-chan string, errs <-chan error, run func(job *cronJobConfiguration),
+) error {
+	debounce, _ := w.config.debounce()
+
+	var timer, retry clockTimer
+
+	// restart starts waiting for changes to settle, or for job to be allowed to run, for d
+	restart := func(d time.Duration) {
+		if timer != nil {
+			timer.Stop()
+		}
+
+		timer = w.clock.NewTimer(d)
+	}
+
+	defer func() {
+		for _, t := range []clockTimer{timer, retry} {
+			if t != nil {
+				t.Stop()
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-errs:
+			return err
+
+		case path := <-changes:
+			if timer == nil {
+				fmt.Printf("watch job '%s': change detected: %s\n", job.Name, path)
+			}
+
+			restart(debounce)
+
+			if retry == nil && w.hasMissing() {
+				retry = w.clock.NewTimer(watchRetryInterval)
+			}
+
+		case <-timerChan(retry):
+			retry = nil
+
+			if w.addMissing() {
+				restart(debounce)
+			}
+
+			if w.hasMissing() {
+				retry = w.clock.NewTimer(watchRetryInterval)
+			}
+
+		case <-timerChan(timer):
+			timer = nil
+
+			now := w.clock.Now()
+
+			window, err := checkRunWindow(job, blackouts, now, false)
+
+			switch {
+			case err != nil:
+				fmt.Fprintln(os.Stderr, fmt.Errorf("watch job '%s': %w", job.Name, err).Error())
+
+			case !window.skipUntil.IsZero():
+				fmt.Printf("job '%s' skipped: %s\n", job.Name, window.reason)
+
+			case window.at.After(now):
+				fmt.Printf("job '%s' %s until %s\n", job.Name, window.reason, window.at.Format(time.DateTime))
+
+				restart(window.at.Sub(now))
+
+			default:
+				run(job)
+			}
+		}
+	}
+}
+
+// timerChan returns the channel of timer, or nil if there is no timer, so that it never fires.
+func timerChan(timer clockTimer) <-chan time.Time {
+	if timer == nil {
+		return nil
+	}
+
+	return timer.C()
+}
+
+func (w *watcher) read(ctx context.Context, changes chan<- string) error {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		count, err := w.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("read inotify events: %w", err)
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := strings.TrimRight(string(buf[offset+syscall.SizeofInotifyEvent:offset+syscall.SizeofInotifyEvent+int(event.Len)]), "\x00")
+
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			path, ok := w.handle(event, name)
+			if !ok {
+				continue
+			}
+
+			select {
+			case changes <- path:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// handle processes a single inotify event and returns the changed path, if it is relevant.
+func (w *watcher) handle(event *syscall.InotifyEvent, name string) (string, bool) {
+	if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		return "(event queue overflow)", true
+	}
+
+	w.mu.Lock()
+	dir, ok := w.paths[event.Wd]
+	root := w.roots[event.Wd]
+	w.mu.Unlock()
+
+	if !ok {
+		return "", false
+	}
+
+	if event.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+		// removed directories below the root are reported by their parent
+		if dir != root {
+			return "", false
+		}
+
+		fmt.Printf("watch '%s': removed, waiting for it to be recreated\n", root)
+
+		w.removeRoot(root)
+
+		return root, true
+	}
+
+	if event.Mask&syscall.IN_IGNORED != 0 {
+		w.mu.Lock()
+		delete(w.paths, event.Wd)
+		delete(w.roots, event.Wd)
+		w.mu.Unlock()
+
+		return "", false
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+
+	if w.config.ignored(root, path) {
+		return "", false
+	}
+
+	if event.Mask&syscall.IN_ISDIR == 0 {
+		return path, w.config.matches(root, path)
+	}
+
+	if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && w.config.Recursive {
+		if err := w.add(root, path); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("watch '%s': %w", path, err).Error())
+		}
+	}
+
+	return path, len(w.config.Globs) == 0
+}
+
+// removeRoot removes the watches of root and the directories below it, and remembers root as missing.
+func (w *watcher) removeRoot(root string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for wd, other := range w.roots {
+		if other != root {
+			continue
+		}
+
+		// the watch is already gone if its directory has been deleted
+		_, _ = syscall.InotifyRmWatch(w.fd, uint32(wd))
+
+		delete(w.paths, wd)
+		delete(w.roots, wd)
+	}
+
+	w.missing[root] = struct{}{}
+}
+
+func (w *watcher) hasMissing() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.missing) > 0
+}
+
+// addMissing watches the missing roots again that have been recreated, and returns whether there were any.
+func (w *watcher) addMissing() bool {
+	w.mu.Lock()
+
+	roots := make([]string, 0, len(w.missing))
+	for root := range w.missing {
+		roots = append(roots, root)
+	}
+
+	w.mu.Unlock()
+
+	added := false
+
+	for _, root := range roots {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+
+		if err := w.add(root, root); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("watch '%s': %w", root, err).Error())
+			continue
+		}
+
+		fmt.Printf("watch '%s': recreated\n", root)
+
+		w.mu.Lock()
+		delete(w.missing, root)
+		w.mu.Unlock()
+
+		added = true
+	}
+
+	return added
+}
+
+// add adds a watch for path, which is either the root itself or a directory below root.
+// Directories below path are added as well if the configuration is recursive.
+func (w *watcher) add(root string, path string) error {
+	return filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk: %w", err)
+		}
+
+		if path != root {
+			if !entry.IsDir() {
+				return nil
+			}
+
+			if !w.config.Recursive || w.config.ignored(root, path) {
+				return filepath.SkipDir
+			}
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return fmt.Errorf("add watch '%s': %w", path, err)
+		}
+
+		w.mu.Lock()
+		w.paths[int32(wd)] = path
+		w.roots[int32(wd)] = root
+		w.mu.Unlock()
+
+		return nil
+	})
+}
+
+// ignored returns whether path, which is root or a path below root, matches any ignore pattern.
+func (c *watchConfiguration) ignored(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return true
+	}
+
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		for _, pattern := range c.Ignore {
+			if ok, _ := filepath.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matches returns whether a change to the file at path, which is root or a path below root,
+// should trigger the job. Globs are matched against the file's base name and its path relative to root.
+func (c *watchConfiguration) matches(root string, path string) bool {
+	if len(c.Globs) == 0 || path == root {
+		return true
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range c.Globs {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks that the watch has a valid debounce and that all of its paths exist.
+func (c *watchConfiguration) validate() error {
+	if len(c.Paths) == 0 {
+		return errNoWatchPaths
+	}
+
+	if _, err := c.debounce(); err != nil {
+		return err
+	}
+
+	for _, path := range c.Paths {
+		if _, err := os.Stat(expandHome(path)); err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *watchConfiguration) debounce() (time.Duration, error) {
+	if c.Debounce == "" {
+		return defaultWatchDebounce, nil
+	}
+
+	debounce, err := time.ParseDuration(c.Debounce)
+	if err != nil {
+		return 0, fmt.Errorf("debounce '%s': %w", c.Debounce, err)
+	}
+
+	return debounce, nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingExecutor runs commands until they are released or their context is canceled.
+type blockingExecutor struct {
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func (e *blockingExecutor) execute(ctx context.Context, _ *cronJobConfiguration, _ string, _ []string,
+	_ io.Writer, _ io.Writer,
+) (int, error) {
+	e.started <- struct{}{}
+
+	select {
+	case <-e.release:
+		return 0, nil
+	case <-ctx.Done():
+		close(e.canceled)
+		return -1, ctx.Err()
+	}
+}
+
+func TestWatchSet_StopKeepsRuns(t *testing.T) {
+	dir := t.TempDir()
+
+	exe := blockingExecutor{
+		started:  make(chan struct{}, 1),
+		release:  make(chan struct{}),
+		canceled: make(chan struct{}),
+	}
+
+	run := newRunner(1024)
+	run.executor = &exe
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	watches := newWatchSet(ctx, run)
+
+	defer func() {
+		cancel()
+		watches.wait()
+	}()
+
+	job := cronJobConfiguration{
+		Name:  "job",
+		Watch: &watchConfiguration{Paths: []string{dir}, Debounce: "10ms"},
+	}
+
+	watches.start(&job)
+
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-exe.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run after change")
+	}
+
+	// stopping the watcher, as on reload, returns right away and does not cancel the run
+	watches.stop(job.Name)
+
+	select {
+	case <-exe.canceled:
+		t.Fatal("run canceled by stopping the watcher")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(exe.release)
+}
+
+func TestWatchConfiguration_Matches(t *testing.T) {
+	for _, test := range []struct {
+		globs []string
+		path  string
+		want  bool
+	}{
+		{nil, "/srv/any/file", true},
+		{[]string{"*.conf"}, "/srv", true},
+		{[]string{"*.conf"}, "/srv/app.conf", true},
+		{[]string{"*.conf"}, "/srv/deep/dir/app.conf", true},
+		{[]string{"*.conf"}, "/srv/readme.md", false},
+		{[]string{"sites/*.yaml"}, "/srv/sites/a.yaml", true},
+		{[]string{"sites/*.yaml"}, "/srv/other/a.yaml", false},
+		{[]string{"*.md", "sites/*.yaml"}, "/srv/readme.md", true},
+	} {
+		config := watchConfiguration{Globs: test.globs}
+
+		if got := config.matches("/srv", test.path); got != test.want {
+			t.Errorf("globs %v: matches(%s) = %t, want %t", test.globs, test.path, got, test.want)
+		}
+	}
+}
+
+func TestWatchConfiguration_Ignored(t *testing.T) {
+	config := watchConfiguration{Ignore: []string{".git", "*.swp"}}
+
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"/srv", false},
+		{"/srv/.git", true},
+		{"/srv/.git/HEAD", true},
+		{"/srv/dir/.file.swp", true},
+		{"/srv/dir/file.txt", false},
+		{"/srv/git/file", false},
+	} {
+		if got := config.ignored("/srv", test.path); got != test.want {
+			t.Errorf("ignored(%s) = %t, want %t", test.path, got, test.want)
+		}
+	}
+}
+
+func TestWatcher_Debounce(t *testing.T) {
+	clk := newFakeClock()
+	start := clk.Now()
+
+	watch := watcher{config: &watchConfiguration{Debounce: "1s"}, clock: clk}
+
+	changes := make(chan string)
+	errs := make(chan error)
+	calls := make(chan runCall, 10)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- watch.handleChanges(context.Background(), &cronJobConfiguration{Name: "job"}, nil, changes, errs,
+			func(job *cronJobConfiguration) {
+				calls <- runCall{job: job.Name, time: clk.Now()}
+			})
+	}()
+
+	changes <- "a"
+
+	clk.waitTimerAt(t, start.Add(time.Second))
+	clk.advance(500 * time.Millisecond)
+	expectNoRun(t, calls)
+
+	// every change restarts the debounce, so changes in quick succession run the job once
+	changes <- "b"
+
+	clk.waitTimerAt(t, start.Add(1500*time.Millisecond))
+	clk.advance(500 * time.Millisecond)
+	expectNoRun(t, calls)
+
+	clk.advance(500 * time.Millisecond)
+	expectRun(t, calls, "job", start.Add(1500*time.Millisecond))
+	expectNoRun(t, calls)
+
+	readErr := errors.New("read failed")
+	errs <- readErr
+
+	if err := <-done; !errors.Is(err, readErr) {
+		t.Errorf("err = %v, want %v", err, readErr)
+	}
+}
+
+func TestWatcher_RootRecreated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "watched")
+
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	clk := newFakeClock()
+
+	watch, err := newWatcher(&watchConfiguration{Paths: []string{dir}, Debounce: "1s"}, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(chan runCall, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- watch.run(ctx, &cronJobConfiguration{Name: "job"}, nil, func(job *cronJobConfiguration) {
+			calls <- runCall{job: job.Name, time: clk.Now()}
+		})
+	}()
+
+	defer func() {
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// removing the watched directory is a change, and it is checked for being recreated
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.waitTimers(t, 2)
+	clk.advance(time.Second)
+	expectRun(t, calls, "job", clk.Now())
+
+	clk.waitTimers(t, 1)
+
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	// recreating it is a change as well
+	clk.advance(watchRetryInterval)
+	clk.waitTimers(t, 1)
+	clk.advance(time.Second)
+	expectRun(t, calls, "job", clk.Now())
+
+	// and it is watched again
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.waitTimers(t, 1)
+	clk.advance(time.Second)
+	expectRun(t, calls, "job", clk.Now())
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	blackoutSkip  = "skip"
+	blackoutDefer = "defer"
+
+	// maximum number of run times to check before giving up on finding one that all windows allow
+	maxWindowCandidates = 1000
+)
+
+var (
+	errInvalidWindow         = errors.New("invalid time window")
+	errInvalidWeekday        = errors.New("invalid weekday")
+	errInvalidBlackoutAction = errors.New("invalid blackout action")
+	errNoAllowedRunTime      = errors.New("no run time outside of blackout and inside of activity window")
+	errNoBlackoutTime        = errors.New("missing between, which would black out all time")
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// timeWindow is a daily period of time, optionally restricted to certain weekdays.
+// Windows that end before they start span midnight, and their weekday is the day they start on.
+type timeWindow struct {
+	fromHour, fromMinute int
+	toHour, toMinute     int
+
+	// nil for every day
+	weekdays map[time.Weekday]bool
+}
+
+// runWindow is the outcome of checking a run time against a job's activity window and the blackout windows.
+type runWindow struct {
+	// run time to use, which is later than the checked time if the run has been deferred
+	at time.Time
+
+	// time from which to look for the next run if the run has been skipped, or zero
+	skipUntil time.Time
+
+	reason string
+}
+
+// parseTimeWindow parses a window in the form "HH:MM-HH:MM" on the given weekdays.
+// An empty between means the whole day. Weekdays are given as "mon", "tue" etc., or ranges such as "mon-fri".
+func parseTimeWindow(between string, weekdays []string) (*timeWindow, error) {
+	window := timeWindow{}
+
+	if between != "" {
+		from, to, ok := strings.Cut(between, "-")
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", between, errInvalidWindow)
+		}
+
+		var err error
+
+		if window.fromHour, window.fromMinute, err = parseTimeOfDay(from); err != nil {
+			return nil, fmt.Errorf("%s: %w", between, err)
+		}
+
+		if window.toHour, window.toMinute, err = parseTimeOfDay(to); err != nil {
+			return nil, fmt.Errorf("%s: %w", between, err)
+		}
+	}
+
+	if len(weekdays) == 0 {
+		return &window, nil
+	}
+
+	window.weekdays = map[time.Weekday]bool{}
+
+	for _, spec := range weekdays {
+		if err := window.addWeekdays(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return &window, nil
+}
+
+func parseTimeOfDay(value string) (int, int, error) {
+	hourStr, minuteStr, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return 0, 0, errInvalidWindow
+	}
+
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, 0, errInvalidWindow
+	}
+
+	minute, err := strconv.Atoi(minuteStr)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, 0, errInvalidWindow
+	}
+
+	return hour, minute, nil
+}
+
+func (w *timeWindow) addWeekdays(spec string) error {
+	fromStr, toStr, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), "-")
+
+	from, ok := parseWeekday(fromStr)
+	if !ok {
+		return fmt.Errorf("%s: %w", spec, errInvalidWeekday)
+	}
+
+	to := from
+
+	if isRange {
+		if to, ok = parseWeekday(toStr); !ok {
+			return fmt.Errorf("%s: %w", spec, errInvalidWeekday)
+		}
+	}
+
+	for day := from; ; day = (day + 1) % 7 {
+		w.weekdays[day] = true
+
+		if day == to {
+			return nil
+		}
+	}
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	if len(name) < 3 {
+		return 0, false
+	}
+
+	day, ok := weekdayNames[name[:3]]
+	if !ok || !strings.HasPrefix(strings.ToLower(day.String()), name) {
+		return 0, false
+	}
+
+	return day, true
+}
+
+// bounds returns the start and end of the window that starts on the day of t, shifted by offset days.
+func (w *timeWindow) bounds(t time.Time, offset int) (time.Time, time.Time) {
+	year, month, day := t.Date()
+
+	start := time.Date(year, month, day+offset, w.fromHour, w.fromMinute, 0, 0, t.Location())
+	end := time.Date(year, month, day+offset, w.toHour, w.toMinute, 0, 0, t.Location())
+
+	if !end.After(start) {
+		end = time.Date(year, month, day+offset+1, w.toHour, w.toMinute, 0, 0, t.Location())
+	}
+
+	return start, end
+}
+
+func (w *timeWindow) activeOn(day time.Weekday) bool {
+	return w.weekdays == nil || w.weekdays[day]
+}
+
+// end returns the end of the window that contains t, if any.
+func (w *timeWindow) end(t time.Time) (time.Time, bool) {
+	for _, offset := range []int{-1, 0} {
+		start, end := w.bounds(t, offset)
+
+		if w.activeOn(start.Weekday()) && !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// next returns the start and end of the first window that starts after t.
+func (w *timeWindow) next(t time.Time) (time.Time, time.Time) {
+	for offset := 0; ; offset++ {
+		start, end := w.bounds(t, offset)
+
+		if w.activeOn(start.Weekday()) && start.After(t) {
+			return start, end
+		}
+	}
+}
+
+// activityWindow returns the window in which job may run, or nil if it may run at any time.
+func (job *cronJobConfiguration) activityWindow() (*timeWindow, error) {
+	if job.OnlyBetween == "" && len(job.Weekdays) == 0 {
+		return nil, nil
+	}
+
+	return parseTimeWindow(job.OnlyBetween, job.Weekdays)
+}
+
+func (b *blackoutConfiguration) window() (*timeWindow, error) {
+	switch b.Action {
+	case "", blackoutSkip, blackoutDefer:
+	default:
+		return nil, fmt.Errorf("%s: %w", b.Action, errInvalidBlackoutAction)
+	}
+
+	if b.Between == "" {
+		return nil, errNoBlackoutTime
+	}
+
+	return parseTimeWindow(b.Between, b.Weekdays)
+}
+
+func (b *blackoutConfiguration) String() string {
+	desc := "blackout " + b.Between
+
+	if len(b.Weekdays) > 0 {
+		desc += " on " + strings.Join(b.Weekdays, ",")
+	}
+
+	return desc
+}
+
+// validateBlackouts checks that all blackout windows of c are valid.
+func (c *cronConfiguration) validateBlackouts() error {
+	for idx, blackout := range c.Blackout {
+		if _, err := blackout.window(); err != nil {
+			return fmt.Errorf("blackout %d: %w", idx+1, err)
+		}
+	}
+
+	return nil
+}
+
+// checkRunWindow checks whether job may run at t, given its activity window and the blackout windows.
+// Runs outside of the activity window are deferred to its next start. Runs in a blackout are skipped
+// or deferred to its end, depending on the blackout's action, but always deferred if once is set.
+func checkRunWindow(job *cronJobConfiguration, blackouts []*blackoutConfiguration, t time.Time, once bool,
+) (runWindow, error) {
+	active, err := job.activityWindow()
+	if err != nil {
+		return runWindow{}, err
+	}
+
+	result := runWindow{at: t}
+
+	// a deferred run may fall into another window, so check again until the run time is stable
+	for range maxWindowCandidates {
+		if active != nil {
+			if _, ok := active.end(result.at); !ok {
+				result.at, _ = active.next(result.at)
+				result.reason = "deferred to activity window " + job.describeWindow()
+
+				continue
+			}
+		}
+
+		blackout, end, err := findBlackout(blackouts, result.at)
+		if err != nil {
+			return runWindow{}, err
+		}
+
+		if blackout == nil {
+			return result, nil
+		}
+
+		if blackout.Action != blackoutDefer && !once {
+			return runWindow{
+				skipUntil: end,
+				reason:    "in " + blackout.String(),
+			}, nil
+		}
+
+		result.at = end
+		result.reason = "deferred by " + blackout.String()
+	}
+
+	return runWindow{}, errNoAllowedRunTime
+}
+
+func findBlackout(blackouts []*blackoutConfiguration, t time.Time) (*blackoutConfiguration, time.Time, error) {
+	for _, blackout := range blackouts {
+		window, err := blackout.window()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		if end, ok := window.end(t); ok {
+			return blackout, end, nil
+		}
+	}
+
+	return nil, time.Time{}, nil
+}
+
+// nextAllowedRun returns the first time at or after after at which job is scheduled to run, taking
+// its activity window and the blackout windows into account. Runs that fall outside of the activity
+// window are skipped if another run falls into its next occurrence, and deferred to its start otherwise.
+// Runs that fall into a skipping blackout are skipped, unless they are the only run of job, and runs that
+// fall into a deferring blackout are deferred to its end. It returns false if job has no further runs,
+// or has run at its specific time already.
+func nextAllowedRun(job *cronJobConfiguration, blackouts []*blackoutConfiguration, start time.Time, after time.Time,
+) (runWindow, bool, error) {
+	if job.atDone() {
+		return runWindow{}, false, nil
+	}
+
+	active, err := job.activityWindow()
+	if err != nil {
+		return runWindow{}, false, err
+	}
+
+	for range maxWindowCandidates {
+		times, err := nextRunTimes(job, start, after, 1)
+		if err != nil || len(times) == 0 {
+			return runWindow{}, false, err
+		}
+
+		at := times[0]
+
+		if active != nil && job.Every != "" {
+			if _, ok := active.end(at); !ok {
+				windowStart, windowEnd := active.next(at)
+
+				inside, err := nextRunTimes(job, start, windowStart, 1)
+				if err != nil {
+					return runWindow{}, false, err
+				}
+
+				if len(inside) > 0 && inside[0].Before(windowEnd) {
+					at = inside[0]
+				}
+			}
+		}
+
+		run, err := checkRunWindow(job, blackouts, at, job.Every == "")
+		if err != nil {
+			return runWindow{}, false, err
+		}
+
+		if run.skipUntil.IsZero() {
+			return run, true, nil
+		}
+
+		after = run.skipUntil
+	}
+
+	return runWindow{}, false, errNoAllowedRunTime
+}
+
+// describeWindow describes the activity window of job, or returns an empty string if it has none.
+func (job *cronJobConfiguration) describeWindow() string {
+	var parts []string
+
+	if job.OnlyBetween != "" {
+		parts = append(parts, "between "+job.OnlyBetween)
+	}
+
+	if len(job.Weekdays) > 0 {
+		parts = append(parts, "on "+strings.Join(job.Weekdays, ","))
+	}
+
+	return strings.Join(parts, " ")
+}
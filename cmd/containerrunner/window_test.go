@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func expectRuns(t *testing.T, job *cronJobConfiguration, blackouts []*blackoutConfiguration, start time.Time, want ...time.Time) {
+	t.Helper()
+
+	runs, err := nextAllowedRuns(job, blackouts, start, len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != len(want) {
+		t.Fatalf("got %d runs, want %d", len(runs), len(want))
+	}
+
+	for idx, run := range runs {
+		if !run.at.Equal(want[idx]) {
+			t.Errorf("run %d at %s, want %s", idx+1, run.at, want[idx])
+		}
+	}
+}
+
+func TestNextAllowedRun_ActivityWindow(t *testing.T) {
+	start := newFakeClock().Now()
+	day := start.Truncate(24 * time.Hour)
+
+	job := cronJobConfiguration{Name: "job", Every: "4h", OnlyBetween: "19:00-07:00"}
+
+	expectRuns(t, &job, nil, start,
+		day.Add(20*time.Hour),
+		day.Add(24*time.Hour),
+		day.Add(28*time.Hour),
+		day.Add(44*time.Hour),
+	)
+}
+
+func TestNextAllowedRun_Weekdays(t *testing.T) {
+	// a Friday
+	start := newFakeClock().Now().Add(4 * 24 * time.Hour)
+
+	job := cronJobConfiguration{Name: "job", Every: "24h", Weekdays: []string{"mon-fri"}}
+
+	expectRuns(t, &job, nil, start,
+		start,
+		start.Add(3*24*time.Hour),
+		start.Add(4*24*time.Hour),
+	)
+}
+
+func TestNextAllowedRun_Blackout(t *testing.T) {
+	start := newFakeClock().Now()
+
+	job := cronJobConfiguration{Name: "job", Every: "1h"}
+
+	skip := []*blackoutConfiguration{{Between: "12:30-13:30"}}
+
+	expectRuns(t, &job, skip, start,
+		start,
+		start.Add(2*time.Hour),
+	)
+
+	deferred := []*blackoutConfiguration{{Between: "12:30-13:30", Action: blackoutDefer}}
+
+	expectRuns(t, &job, deferred, start,
+		start,
+		start.Add(90*time.Minute),
+		start.Add(2*time.Hour),
+	)
+}
+
+func TestNextAllowedRun_DeferToActivityWindow(t *testing.T) {
+	start := newFakeClock().Now()
+	day := start.Truncate(24 * time.Hour)
+
+	// no scheduled run falls into the window, so runs are deferred to its start
+	daily := cronJobConfiguration{Name: "daily", Every: "24h", OnlyBetween: "03:00-05:00"}
+
+	expectRuns(t, &daily, nil, start,
+		day.Add(27*time.Hour),
+		day.Add(51*time.Hour),
+		day.Add(75*time.Hour),
+	)
+
+	// a deferred run may fall into a blackout, and a blackout may defer a run out of the window
+	deferred := []*blackoutConfiguration{{Between: "02:30-03:30", Action: blackoutDefer}}
+
+	expectRuns(t, &daily, deferred, start,
+		day.Add(27*time.Hour+30*time.Minute),
+		day.Add(51*time.Hour+30*time.Minute),
+	)
+
+	late := []*blackoutConfiguration{{Between: "04:30-06:00", Action: blackoutDefer}}
+	hourly := cronJobConfiguration{Name: "hourly", Every: "1h", OnlyBetween: "03:00-05:00"}
+
+	expectRuns(t, &hourly, late, day.Add(28*time.Hour+45*time.Minute),
+		day.Add(51*time.Hour),
+		day.Add(51*time.Hour+45*time.Minute),
+		day.Add(75*time.Hour),
+	)
+
+	covered := []*blackoutConfiguration{{Between: "02:00-06:00", Action: blackoutDefer}}
+
+	if _, _, err := nextAllowedRun(&daily, covered, start, start); !errors.Is(err, errNoAllowedRunTime) {
+		t.Errorf("err = %v, want %v", err, errNoAllowedRunTime)
+	}
+}
+
+func TestNextAllowedRun_Once(t *testing.T) {
+	start := newFakeClock().Now()
+	day := start.Truncate(24 * time.Hour)
+
+	for _, test := range []struct {
+		name      string
+		job       cronJobConfiguration
+		blackouts []*blackoutConfiguration
+		want      time.Time
+	}{
+		{"outside of activity window", cronJobConfiguration{OnlyBetween: "03:00-05:00"}, nil, day.Add(27 * time.Hour)},
+		{"in skipping blackout", cronJobConfiguration{}, []*blackoutConfiguration{{Between: "11:00-13:00"}}, day.Add(13 * time.Hour)},
+		{
+			"at in skipping blackout",
+			cronJobConfiguration{At: day.Add(14 * time.Hour).Format(time.RFC3339)},
+			[]*blackoutConfiguration{{Between: "13:00-15:00"}},
+			day.Add(15 * time.Hour),
+		},
+	} {
+		test.job.Name = "once"
+
+		// the only run of a job is deferred rather than never run
+		runs, err := nextAllowedRuns(&test.job, test.blackouts, start, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if len(runs) != 1 || !runs[0].at.Equal(test.want) || runs[0].reason == "" {
+			t.Errorf("%s: runs = %+v, want one deferred run at %s", test.name, runs, test.want)
+		}
+	}
+}
+
+func TestValidateBlackouts(t *testing.T) {
+	for _, test := range []struct {
+		blackout blackoutConfiguration
+		want     error
+	}{
+		{blackoutConfiguration{Between: "01:00-02:00", Action: blackoutDefer}, nil},
+		{blackoutConfiguration{Between: "01:00-02:00", Action: "postpone"}, errInvalidBlackoutAction},
+		{blackoutConfiguration{Weekdays: []string{"sat", "sun"}}, errNoBlackoutTime},
+		{blackoutConfiguration{}, errNoBlackoutTime},
+	} {
+		config := cronConfiguration{Blackout: []*blackoutConfiguration{&test.blackout}}
+
+		if err := config.validateBlackouts(); !errors.Is(err, test.want) {
+			t.Errorf("%+v: err = %v, want %v", test.blackout, err, test.want)
+		}
+	}
+}
+
+func TestParseTimeWindow_Invalid(t *testing.T) {
+	for _, window := range []struct {
+		between  string
+		weekdays []string
+	}{
+		{"19:00", nil},
+		{"25:00-07:00", nil},
+		{"19:00-07:60", nil},
+		{"", []string{"someday"}},
+		{"", []string{"mon-xyz"}},
+	} {
+		if _, err := parseTimeWindow(window.between, window.weekdays); err == nil {
+			t.Errorf("parse %q %v: expected error", window.between, window.weekdays)
+		}
+	}
+}
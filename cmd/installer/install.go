@@ -111,7 +111,8 @@ var (
 
 	gitPromptURL   = "https://raw.githubusercontent.com/git/git/master/contrib/completion/git-prompt.sh"
 	gitPromptZSHRC = `setopt PROMPT_SUBST` + "\n" +
-		`PS1=$'%{\e[30;44m%} %d %{\e[00m\e[30;42m%}\$(__git_ps1 \" %s \")%{\e[00m%}%(?..%{\e[30;41m%} %? %{\e[00m%}) '` + "\n"
+		`__containerrunner_ps1() { local s=$(~/containerrunner status -short 2>/dev/null); [[ -n $s ]] && print -rn -- $'%{\e[30;41m%} '$s$' %{\e[00m%}' }` + "\n" +
+		`PS1=$'%{\e[30;44m%} %d %{\e[00m\e[30;42m%}\$(__git_ps1 \" %s \")%{\e[00m%}\$(__containerrunner_ps1)%(?..%{\e[30;41m%} %? %{\e[00m%}) '` + "\n"
 )
 
 const tz = "Europe/Berlin"